@@ -1,6 +1,7 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io/ioutil"
@@ -46,6 +47,8 @@ func RequestInfo(c *gin.Context) map[string]interface{} {
 	} else {
 		body = fmt.Sprintf("%v", string(bodyByte))
 	}
+	// 读取后放回body，后续handler仍可读取
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(bodyByte))
 
 	return map[string]interface{}{
 		"headers": c.Request.Header,
@@ -0,0 +1,140 @@
+package app
+
+import (
+	cryptoRand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IDGenerator 生成请求id等唯一id
+type IDGenerator func() string
+
+// crockford ULID 使用的base32字母表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// randomBytes 读取随机数，crypto/rand 失败时panic，不会退回可预测的 math/rand
+func randomBytes(b []byte) {
+	if _, err := cryptoRand.Read(b); err != nil {
+		panic(fmt.Sprintf("app: read crypto/rand failed: %s", err))
+	}
+}
+
+// formatUUID 按 8-4-4-4-12 格式输出
+func formatUUID(b []byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf)
+}
+
+// NewUUIDv4 生成随机 UUID
+func NewUUIDv4() string {
+	b := make([]byte, 16)
+	randomBytes(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// NewUUIDv7 生成以毫秒时间戳开头、按时间有序的 UUID
+func NewUUIDv7() string {
+	b := make([]byte, 16)
+	randomBytes(b[6:])
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+// NewULID 生成26位 ULID，48位毫秒时间戳 + 80位随机数
+func NewULID() string {
+	b := make([]byte, 16)
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+	randomBytes(b[6:])
+
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	// 128位从低位开始每5位编码一个字符，首字符只有3位
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// snowflakeEpoch 雪花算法起始时间 2020-01-01 00:00:00 UTC
+const snowflakeEpoch int64 = 1577836800000
+
+// Snowflake 雪花算法，41位毫秒时间戳 + 10位节点 + 12位序列号
+type Snowflake struct {
+	mu       sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+// NewSnowflake 创建雪花算法生成器，node 取值 0-1023
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > 1023 {
+		return nil, errors.New("snowflake node must be between 0 and 1023")
+	}
+	return &Snowflake{node: node}, nil
+}
+
+// Next 生成下一个id，同一毫秒序列号用完时等待下一毫秒
+func (s *Snowflake) Next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+	if now < s.lastTime {
+		// 时钟回拨时沿用上次的时间，保证id递增
+		now = s.lastTime
+	}
+	if now == s.lastTime {
+		s.sequence = (s.sequence + 1) & 0xfff
+		if s.sequence == 0 {
+			for now <= s.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixNano()/int64(time.Millisecond) - snowflakeEpoch
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastTime = now
+	return now<<22 | s.node<<12 | s.sequence
+}
+
+// Generator 以十进制字符串返回id
+func (s *Snowflake) Generator() IDGenerator {
+	return func() string {
+		return strconv.FormatInt(s.Next(), 10)
+	}
+}
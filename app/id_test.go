@@ -0,0 +1,82 @@
+package app
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewUUIDv4(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := NewUUIDv4()
+		m := uuidPattern.FindStringSubmatch(id)
+		if m == nil || m[1] != "4" {
+			t.Fatalf("NewUUIDv4() = %q, want version 4 uuid", id)
+		}
+		if seen[id] {
+			t.Fatalf("NewUUIDv4() returned duplicate %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id := NewUUIDv7()
+		m := uuidPattern.FindStringSubmatch(id)
+		if m == nil || m[1] != "7" {
+			t.Fatalf("NewUUIDv7() = %q, want version 7 uuid", id)
+		}
+		// 前48位为毫秒时间戳，不同毫秒生成的id按时间有序
+		if prev != "" && id[:13] < prev[:13] {
+			t.Fatalf("NewUUIDv7() = %q, not ordered after %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id := NewULID()
+		if !pattern.MatchString(id) {
+			t.Fatalf("NewULID() = %q, want 26 crockford base32 chars", id)
+		}
+		if prev != "" && id[:10] < prev[:10] {
+			t.Fatalf("NewULID() = %q, not ordered after %q", id, prev)
+		}
+		prev = id
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	if _, err := NewSnowflake(1024); err == nil {
+		t.Error("NewSnowflake(1024) should fail")
+	}
+	s, err := NewSnowflake(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prev int64
+	for i := 0; i < 10000; i++ {
+		id := s.Next()
+		if id <= prev {
+			t.Fatalf("Next() = %d, not greater than %d", id, prev)
+		}
+		if node := id >> 12 & 0x3ff; node != 7 {
+			t.Fatalf("Next() node = %d, want 7", node)
+		}
+		prev = id
+	}
+
+	id, err := strconv.ParseInt(s.Generator()(), 10, 64)
+	if err != nil || id <= prev {
+		t.Errorf("Generator() = %d, %v, want id greater than %d", id, err, prev)
+	}
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"context"
 	"github.com/getsentry/sentry-go"
+	"time"
 )
 
 // CtxValueCommonKey ctx common value key
@@ -12,6 +13,10 @@ type CtxValueCommonKey string
 const (
 	// CtxValueCommonKeyTraceID traceId
 	CtxValueCommonKeyTraceID CtxValueCommonKey = "traceId"
+	// CtxValueCommonKeyClientIP 经过可信代理解析后的客户端IP
+	CtxValueCommonKeyClientIP CtxValueCommonKey = "clientIp"
+	// CtxValueCommonKeyTenantID 租户id
+	CtxValueCommonKeyTenantID CtxValueCommonKey = "tenantId"
 )
 
 // CtxValueKey ctx value key
@@ -51,6 +56,19 @@ func (v *CtxValue) SetSentryHub(hub *sentry.Hub) *CtxValue {
 	return v
 }
 
+// Clone 复制 common value 和 sentry hub，在新的goroutine中使用，避免并发读写同一个map
+func (v *CtxValue) Clone() *CtxValue {
+	common := make(map[CtxValueCommonKey]string, len(v.common))
+	for key, value := range v.common {
+		common[key] = value
+	}
+	clone := newCtxValue(common)
+	if v.sentryHub != nil {
+		clone.sentryHub = v.sentryHub.Clone()
+	}
+	return clone
+}
+
 // NewCtxValue 创建cxt value
 func NewCtxValue(common map[CtxValueCommonKey]string) *CtxValue {
 	return newCtxValue(common)
@@ -91,8 +109,27 @@ func GetTraceId(ctx context.Context) string {
 	return traceId
 }
 
+// GetClientIP 获取客户端IP
+func GetClientIP(ctx context.Context) string {
+	return GetCtxValue(ctx).GetCommonValue()[CtxValueCommonKeyClientIP]
+}
+
+// GetTenantID 获取租户id
+func GetTenantID(ctx context.Context) string {
+	return GetCtxValue(ctx).GetCommonValue()[CtxValueCommonKeyTenantID]
+}
+
 // SetCtxValue 设置ctx value
 func SetCtxValue(ctx context.Context, value *CtxValue) (context.Context, *CtxValue) {
 	ctx = context.WithValue(ctx, CtxValueKeyV1, value)
 	return ctx, value
 }
+
+// RemainingBudget 获取ctx剩余的处理时间，ctx没有设置deadline时返回false
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
@@ -0,0 +1,59 @@
+package context
+
+import (
+	"context"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// detachedContext 保留parent中的值，不会随parent取消或超时
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (c detachedContext) String() string {
+	return "context.Detach"
+}
+
+// Detach 返回保留ctx中所有值但不会被取消、没有deadline的ctx，用于响应返回后继续执行的异步任务，
+// CtxValue 和 sentry hub 使用副本，避免与请求的goroutine并发修改
+//
+// NOTE: *gin.Context 在请求结束后会被复用，传入 *gin.Context 时使用 c.Request.Context()
+func Detach(ctx context.Context) context.Context {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+
+	var detached context.Context = detachedContext{parent: ctx}
+	var ctxHub, hubClone *sentry.Hub
+	if cv, ok := ctx.Value(CtxValueKeyV1).(*CtxValue); ok {
+		clone := cv.Clone()
+		ctxHub, hubClone = cv.GetSentryHub(), clone.GetSentryHub()
+		detached, _ = SetCtxValue(detached, clone)
+	}
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		if hub != ctxHub || hubClone == nil {
+			hubClone = hub.Clone()
+		}
+		detached = sentry.SetHubOnContext(detached, hubClone)
+	}
+	return detached
+}
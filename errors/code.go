@@ -3,16 +3,30 @@ package errors
 const (
 	Success = 10200
 
-	ErrBadRequest          = 10400
-	ErrStatusUnauthorized  = 10401
-	ErrNotFound            = 10404
-	ErrInternalServerError = 10500
+	ErrBadRequest            = 10400
+	ErrStatusUnauthorized    = 10401
+	ErrForbidden             = 10403
+	ErrNotFound              = 10404
+	ErrConflict              = 10409
+	ErrRequestEntityTooLarge = 10413
+	ErrUnsupportedMediaType  = 10415
+	ErrUnprocessableEntity   = 10422
+	ErrInternalServerError   = 10500
+	ErrServiceUnavailable    = 10503
+	ErrGatewayTimeout        = 10504
 )
 
 var ErrText = map[int]string{
-	Success:                "ok",
-	ErrBadRequest:          "bad request",
-	ErrStatusUnauthorized:  "status unauthorized",
-	ErrNotFound:            "not found",
-	ErrInternalServerError: "internal server error",
+	Success:                  "ok",
+	ErrBadRequest:            "bad request",
+	ErrStatusUnauthorized:    "status unauthorized",
+	ErrForbidden:             "forbidden",
+	ErrNotFound:              "not found",
+	ErrConflict:              "conflict",
+	ErrRequestEntityTooLarge: "request entity too large",
+	ErrUnsupportedMediaType:  "unsupported media type",
+	ErrUnprocessableEntity:   "unprocessable entity",
+	ErrInternalServerError:   "internal server error",
+	ErrServiceUnavailable:    "service unavailable",
+	ErrGatewayTimeout:        "gateway timeout",
 }
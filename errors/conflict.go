@@ -0,0 +1,11 @@
+package errors
+
+type ConflictError struct {
+	*Err
+}
+
+// NewConflictError 创建请求冲突异常
+func NewConflictError(errMsg string) *ConflictError {
+	e := &Err{code: ErrConflict, message: errMsg}
+	return &ConflictError{e}
+}
@@ -0,0 +1,11 @@
+package errors
+
+type ForbiddenError struct {
+	*Err
+}
+
+// NewForbiddenError 创建禁止访问异常
+func NewForbiddenError() *ForbiddenError {
+	e := &Err{code: ErrForbidden, message: ErrText[ErrForbidden]}
+	return &ForbiddenError{e}
+}
@@ -0,0 +1,11 @@
+package errors
+
+type GatewayTimeoutError struct {
+	*Err
+}
+
+// NewGatewayTimeoutError 创建请求处理超时异常
+func NewGatewayTimeoutError() *GatewayTimeoutError {
+	e := &Err{code: ErrGatewayTimeout, message: ErrText[ErrGatewayTimeout]}
+	return &GatewayTimeoutError{e}
+}
@@ -0,0 +1,11 @@
+package errors
+
+type RequestEntityTooLargeError struct {
+	*Err
+}
+
+// NewRequestEntityTooLargeError 创建请求体过大异常
+func NewRequestEntityTooLargeError() *RequestEntityTooLargeError {
+	e := &Err{code: ErrRequestEntityTooLarge, message: ErrText[ErrRequestEntityTooLarge]}
+	return &RequestEntityTooLargeError{e}
+}
@@ -0,0 +1,14 @@
+package errors
+
+type ServiceUnavailableError struct {
+	*Err
+}
+
+// NewServiceUnavailableError 创建服务不可用异常
+func NewServiceUnavailableError(errMsg string) *ServiceUnavailableError {
+	if errMsg == "" {
+		errMsg = ErrText[ErrServiceUnavailable]
+	}
+	e := &Err{code: ErrServiceUnavailable, message: errMsg}
+	return &ServiceUnavailableError{e}
+}
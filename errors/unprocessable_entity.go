@@ -0,0 +1,11 @@
+package errors
+
+type UnprocessableEntityError struct {
+	*Err
+}
+
+// NewUnprocessableEntityError 创建请求无法处理异常
+func NewUnprocessableEntityError(errMsg string) *UnprocessableEntityError {
+	e := &Err{code: ErrUnprocessableEntity, message: errMsg}
+	return &UnprocessableEntityError{e}
+}
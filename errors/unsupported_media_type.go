@@ -0,0 +1,11 @@
+package errors
+
+type UnsupportedMediaTypeError struct {
+	*Err
+}
+
+// NewUnsupportedMediaTypeError 创建不支持的请求类型异常
+func NewUnsupportedMediaTypeError() *UnsupportedMediaTypeError {
+	e := &Err{code: ErrUnsupportedMediaType, message: ErrText[ErrUnsupportedMediaType]}
+	return &UnsupportedMediaTypeError{e}
+}
@@ -15,6 +15,18 @@ import (
 	"time"
 )
 
+// SentryCapturedKey 异常已经上报过sentry时在gin context中设置，SendData 不再重复上报
+const SentryCapturedKey = "SentryCaptured"
+
+// ResCodeKey SendData 在gin context中记录响应的业务code，供访问日志使用
+const ResCodeKey = "ResCode"
+
+// ResErrorKey SendData 返回失败时在gin context中记录error，供链路追踪使用
+const ResErrorKey = "ResError"
+
+// ResTimeoutKey Timeout 中间件已经返回504时在gin context中设置，之后 SendData、SendSuccess 不再记录和写入响应
+const ResTimeoutKey = "ResTimeout"
+
 // Res api response结构
 type Res struct {
 	Success bool        `json:"success"`
@@ -33,6 +45,9 @@ type ResDebug struct {
 
 // SendSuccess 返回成功结果
 func SendSuccess(ctx *gin.Context, resData interface{}) {
+	if ctx.GetBool(ResTimeoutKey) {
+		return
+	}
 	var res *Res
 	res = successRes()
 
@@ -43,17 +58,24 @@ func SendSuccess(ctx *gin.Context, resData interface{}) {
 		log.InfoWithTrace(ctx.Request.Context(), "Response:%s", string(responseByte))
 	}
 
+	ctx.Set(ResCodeKey, res.Code)
 	ctx.JSON(http.StatusOK, res)
 }
 
 // SendData 返回结果
 // 根据err 是否为nil判断返回成功或失败
 func SendData(ctx *gin.Context, resData interface{}, pErr error) {
+	if ctx.GetBool(ResTimeoutKey) {
+		return
+	}
 	var res *Res
 	var httpStatus = http.StatusOK
 	if pErr != nil {
 		res = failedRes()
-		captureException(ctx.Request.Context(), pErr)
+		ctx.Set(ResErrorKey, pErr)
+		if !ctx.GetBool(SentryCapturedKey) {
+			captureException(ctx.Request.Context(), pErr)
+		}
 		if e, ok := pErr.(*errors.Err); ok {
 			httpStatus = http.StatusInternalServerError
 			if code := e.Code(); code != 0 {
@@ -95,6 +117,14 @@ func SendData(ctx *gin.Context, resData interface{}, pErr error) {
 			if msg := e.Message(); msg != "" {
 				res.Msg = msg
 			}
+		} else if e, ok := pErr.(*errors.ForbiddenError); ok {
+			httpStatus = http.StatusForbidden
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
 		} else if e, ok := pErr.(*errors.ErrNotFoundError); ok {
 			httpStatus = http.StatusNotFound
 			if code := e.Code(); code != 0 {
@@ -103,6 +133,54 @@ func SendData(ctx *gin.Context, resData interface{}, pErr error) {
 			if msg := e.Message(); msg != "" {
 				res.Msg = msg
 			}
+		} else if e, ok := pErr.(*errors.ConflictError); ok {
+			httpStatus = http.StatusConflict
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
+		} else if e, ok := pErr.(*errors.RequestEntityTooLargeError); ok {
+			httpStatus = http.StatusRequestEntityTooLarge
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
+		} else if e, ok := pErr.(*errors.UnsupportedMediaTypeError); ok {
+			httpStatus = http.StatusUnsupportedMediaType
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
+		} else if e, ok := pErr.(*errors.UnprocessableEntityError); ok {
+			httpStatus = http.StatusUnprocessableEntity
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
+		} else if e, ok := pErr.(*errors.ServiceUnavailableError); ok {
+			httpStatus = http.StatusServiceUnavailable
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
+		} else if e, ok := pErr.(*errors.GatewayTimeoutError); ok {
+			httpStatus = http.StatusGatewayTimeout
+			if code := e.Code(); code != 0 {
+				res.Code = code
+			}
+			if msg := e.Message(); msg != "" {
+				res.Msg = msg
+			}
 		}
 	} else {
 		res = successRes()
@@ -122,6 +200,7 @@ func SendData(ctx *gin.Context, resData interface{}, pErr error) {
 		log.InfoWithTrace(ctx.Request.Context(), "Response:%s", string(responseByte))
 	}
 
+	ctx.Set(ResCodeKey, res.Code)
 	ctx.JSON(httpStatus, res)
 }
 
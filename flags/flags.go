@@ -0,0 +1,152 @@
+package flags
+
+import (
+	"context"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hlhgogo/gin-ext/log"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+// Flag 功能开关
+type Flag struct {
+	Name string `json:"name"`
+	// Enabled 对所有请求开启
+	Enabled bool `json:"enabled"`
+	// Percentage 按账号放量的百分比，0-100
+	Percentage int `json:"percentage"`
+	// Accounts 直接开启的账号
+	Accounts []string `json:"accounts"`
+}
+
+// Source 功能开关数据来源
+type Source interface {
+	Load(ctx context.Context) (map[string]Flag, error)
+}
+
+// Evaluator 功能开关计算
+type Evaluator struct {
+	source Source
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// defaultEvaluator 默认的功能开关计算，保存 *Evaluator，Load 与读取可能并发
+var defaultEvaluator atomic.Value
+
+func init() {
+	defaultEvaluator.Store(NewEvaluator(nil))
+}
+
+// NewEvaluator 创建功能开关计算，source 为空时所有开关关闭
+func NewEvaluator(source Source) *Evaluator {
+	return &Evaluator{source: source, flags: map[string]Flag{}}
+}
+
+// Load 使用 source 初始化默认的功能开关
+func Load(source Source) error {
+	e := NewEvaluator(source)
+	if err := e.Reload(context.TODO()); err != nil {
+		return err
+	}
+	defaultEvaluator.Store(e)
+	return nil
+}
+
+// Default 获取默认的功能开关计算
+func Default() *Evaluator {
+	return defaultEvaluator.Load().(*Evaluator)
+}
+
+// Enabled 使用默认的功能开关判断当前请求是否开启
+func Enabled(ctx context.Context, name string) bool {
+	return Default().Enabled(ctx, name)
+}
+
+// Get 获取默认功能开关中的配置
+func Get(name string) (Flag, bool) {
+	return Default().Get(name)
+}
+
+// Reload 从 source 重新加载开关
+func (e *Evaluator) Reload(ctx context.Context) error {
+	if e.source == nil {
+		return nil
+	}
+	flags, err := e.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	for name, flag := range flags {
+		flag.Name = name
+		flags[name] = flag
+	}
+	e.mu.Lock()
+	e.flags = flags
+	e.mu.Unlock()
+	return nil
+}
+
+// Watch 定时重新加载开关，返回停止函数
+func (e *Evaluator) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := e.Reload(context.TODO()); err != nil {
+					log.Errorf("Feature flags reload failed: %s", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Get 获取开关配置
+func (e *Evaluator) Get(name string) (Flag, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	flag, ok := e.flags[name]
+	return flag, ok
+}
+
+// Enabled 判断开关对当前请求是否开启，账号取自 tracing.Span.AuthAccountID
+func (e *Evaluator) Enabled(ctx context.Context, name string) bool {
+	flag, ok := e.Get(name)
+	if !ok {
+		return false
+	}
+	if flag.Enabled {
+		return true
+	}
+
+	accountID := tracing.SpanFromContext(ctx).AuthAccountID()
+	// "-" 表示与公司无关的请求，见 tracing.NewAccountIrrelevantContext
+	if accountID == "" || accountID == "-" {
+		return false
+	}
+	for _, account := range flag.Accounts {
+		if account == accountID {
+			return true
+		}
+	}
+	return flag.Percentage > 0 && bucket(name, accountID) < flag.Percentage
+}
+
+// bucket 账号在开关下的分桶，同一账号在同一开关下结果固定
+func bucket(name, accountID string) int {
+	return int(crc32.ChecksumIEEE([]byte(name+":"+accountID)) % 100)
+}
@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+type mapSource map[string]Flag
+
+func (s mapSource) Load(context.Context) (map[string]Flag, error) {
+	flags := map[string]Flag{}
+	for k, v := range s {
+		flags[k] = v
+	}
+	return flags, nil
+}
+
+func TestEvaluator_Enabled(t *testing.T) {
+	e := NewEvaluator(mapSource{
+		"all":     {Enabled: true},
+		"account": {Accounts: []string{"W00000000514"}},
+		"half":    {Percentage: 50},
+	})
+	if err := e.Reload(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		flag    string
+		account string
+		want    bool
+	}{
+		{"missing flag", "missing", "W00000000514", false},
+		{"enabled for all", "all", "", true},
+		{"account allowed", "account", "W00000000514", true},
+		{"account not allowed", "account", "W00000000512", false},
+		{"irrelevant account", "half", "-", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			if tt.account != "" {
+				ctx = tracing.NewContext(ctx, tt.account)
+			}
+			if got := e.Enabled(ctx, tt.flag); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Percentage(t *testing.T) {
+	e := NewEvaluator(mapSource{"half": {Percentage: 50}})
+	if err := e.Reload(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		ctx := tracing.NewContext(context.TODO(), fmt.Sprintf("W%011d", i))
+		if e.Enabled(ctx, "half") {
+			enabled++
+		}
+		if e.Enabled(ctx, "half") != e.Enabled(ctx, "half") {
+			t.Fatal("percentage rollout should be stable for the same account")
+		}
+	}
+	if enabled < 400 || enabled > 600 {
+		t.Errorf("enabled %d of 1000 accounts, want about 500", enabled)
+	}
+}
+
+func TestLoadConcurrent(t *testing.T) {
+	defer Load(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := Load(mapSource{"all": {Enabled: true}}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		Enabled(context.TODO(), "all")
+		Get("all")
+	}
+	<-done
+
+	if !Enabled(context.TODO(), "all") {
+		t.Error("Enabled() = false after Load, want true")
+	}
+}
@@ -0,0 +1,56 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	goRedis "github.com/go-redis/redis/v8"
+	athRedis "github.com/hlhgogo/gin-ext/redis"
+)
+
+// FileSource 从json文件读取开关，格式为 {"name": {"enabled": true, "percentage": 10, "accounts": []}}
+type FileSource struct {
+	Path string
+}
+
+// Load implement Source
+func (s FileSource) Load(_ context.Context) (map[string]Flag, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	flags := map[string]Flag{}
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// RedisSource 从redis hash读取开关，field 为开关名，value 为开关的json
+type RedisSource struct {
+	// Client 为空时使用 redis.DefaultClient()
+	Client *goRedis.Client
+	Key    string
+}
+
+// Load implement Source
+func (s RedisSource) Load(ctx context.Context) (map[string]Flag, error) {
+	cli := s.Client
+	if cli == nil {
+		cli = athRedis.DefaultClient()
+	}
+	values, err := cli.HGetAll(ctx, s.Key).Result()
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]Flag, len(values))
+	for name, value := range values {
+		flag := Flag{}
+		if err := json.Unmarshal([]byte(value), &flag); err != nil {
+			return nil, err
+		}
+		flags[name] = flag
+	}
+	return flags, nil
+}
@@ -1,9 +1,13 @@
 package grequestsx
 
 import (
+	"context"
+	"fmt"
+	athCtx "github.com/hlhgogo/gin-ext/context"
 	"github.com/hlhgogo/gin-ext/log"
 	"github.com/hlhgogo/gin-ext/tracing"
 	"github.com/levigross/grequests"
+	"github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
 	"time"
 )
@@ -82,8 +86,12 @@ func Req(verb string, url string, ro *grequests.RequestOptions, flags ...Flags)
 
 // DoRegularRequest adds generic test functionality
 func DoRegularRequest(requestVerb, url string, ro *grequests.RequestOptions, flags ...Flags) (*grequests.Response, error) {
+	// 复制调用方的配置，超时时间和链路header只对本次请求生效
 	if ro == nil {
 		ro = &grequests.RequestOptions{}
+	} else {
+		roCopy := *ro
+		ro = &roCopy
 	}
 
 	flag := Flags{}
@@ -91,18 +99,34 @@ func DoRegularRequest(requestVerb, url string, ro *grequests.RequestOptions, fla
 		flag = flags[0]
 	}
 
+	// 请求不能超过上游设置的剩余处理时间
+	if ro.Context != nil {
+		if budget, ok := athCtx.RemainingBudget(ro.Context); ok {
+			if budget <= 0 {
+				return nil, fmt.Errorf("grequestsx: no time budget left for %s %s: %w", requestVerb, url, context.DeadlineExceeded)
+			}
+			if ro.RequestTimeout == 0 || ro.RequestTimeout > budget {
+				ro.RequestTimeout = budget
+			}
+		}
+	}
+
+	var clientSpan opentracing.Span
 	if !flag.DisableTrace {
 		if ro.Context != nil {
 			span := tracing.SpanFromContext(ro.Context)
-			for k, v := range span {
-				if ro.Headers == nil {
-					ro.Headers = map[string]string{}
-				}
-				ro.Headers[k] = v
-			}
 			if span.Empty() {
 				log.Warnf("[warning] mesher requested url has no tracing info: %s", url)
 			}
+			clientSpan, span = startClientSpan(span, requestVerb, url)
+			headers := make(map[string]string, len(ro.Headers))
+			for k, v := range ro.Headers {
+				headers[k] = v
+			}
+			for k, v := range injectHeaders(span) {
+				headers[k] = v
+			}
+			ro.Headers = headers
 		} else {
 			log.Warnf("[warning] mesher requested url has no context info: %s", url)
 		}
@@ -111,6 +135,9 @@ func DoRegularRequest(requestVerb, url string, ro *grequests.RequestOptions, fla
 	start := time.Now()
 	response, err := grequests.DoRegularRequest(requestVerb, url, ro)
 	elapsed := time.Since(start)
+	if clientSpan != nil {
+		finishClientSpan(clientSpan, response, err)
+	}
 
 	if !flag.EnableLog {
 		return response, err
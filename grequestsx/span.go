@@ -0,0 +1,64 @@
+package grequestsx
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/levigross/grequests"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// startClientSpan 创建client span，返回的Span使用client span的id，下游的server span会成为它的子span
+func startClientSpan(span tracing.Span, method, rawURL string) (opentracing.Span, tracing.Span) {
+	clientSpan := span.StartChildSpan("HTTP "+method, ext.SpanKindRPCClient)
+	ext.Component.Set(clientSpan, "grequestsx")
+	ext.HTTPMethod.Set(clientSpan, method)
+	ext.HTTPUrl.Set(clientSpan, sanitizeURL(rawURL))
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		ext.PeerHostname.Set(clientSpan, u.Hostname())
+	}
+	return clientSpan, span.WithOpentracing(clientSpan)
+}
+
+// finishClientSpan 记录状态码和错误后结束client span
+func finishClientSpan(clientSpan opentracing.Span, response *grequests.Response, err error) {
+	if response != nil && response.RawResponse != nil {
+		ext.HTTPStatusCode.Set(clientSpan, uint16(response.StatusCode))
+		if response.StatusCode >= http.StatusInternalServerError {
+			ext.Error.Set(clientSpan, true)
+		}
+	}
+	if err != nil {
+		ext.Error.Set(clientSpan, true)
+		clientSpan.LogKV("event", "error", "message", err.Error())
+	}
+	clientSpan.Finish()
+}
+
+// injectHeaders 获取传递给下游的header，key保持小写
+func injectHeaders(span tracing.Span) map[string]string {
+	req := &http.Request{Header: http.Header{}}
+	if err := span.Inject(req); err != nil {
+		return nil
+	}
+	headers := make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = req.Header.Get(k)
+	}
+	return headers
+}
+
+// sanitizeURL 去掉url中的账号密码和查询参数，避免token等敏感信息写入链路
+func sanitizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
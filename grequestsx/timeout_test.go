@@ -0,0 +1,35 @@
+package grequestsx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/levigross/grequests"
+)
+
+func TestRequestBudget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ro := &grequests.RequestOptions{Context: ctx, Headers: map[string]string{"x-app": "test"}}
+	if _, err := Get(srv.URL, ro, Flags{DisableTrace: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ro.RequestTimeout != 0 || len(ro.Headers) != 1 {
+		t.Errorf("caller options changed: timeout = %s, headers = %v", ro.RequestTimeout, ro.Headers)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, err := Get(srv.URL, &grequests.RequestOptions{Context: expired}, Flags{DisableTrace: true}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expired budget error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -50,6 +50,17 @@ func InfoMapWithTrace(ctx context.Context, infos map[string]interface{}, format
 	log.WithFields(fields).Infof(format, args...)
 }
 
+// ErrorMapWithTrace error增加map信息到日志，不上报sentry
+func ErrorMapWithTrace(ctx context.Context, infos map[string]interface{}, format string, args ...interface{}) {
+	fields := getTraceField(ctx)
+	for k, v := range infos {
+		fields[k] = v
+	}
+	msg := fmt.Sprintf(format, args...)
+	addBreadcrumb(ctx, msg, sentry.LevelError)
+	log.WithFields(fields).Error(msg)
+}
+
 // WarnWithTrace warn增加traceId
 func WarnWithTrace(ctx context.Context, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
@@ -142,6 +153,9 @@ func getTraceField(ctx context.Context) logrus.Fields {
 
 	requestId := athCtx.GetTraceId(ctx)
 	fields["traceId"] = requestId
+	if tenantID := athCtx.GetTenantID(ctx); tenantID != "" {
+		fields["tenantId"] = tenantID
+	}
 
 	return fields
 }
@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/config"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat 访问日志输出格式
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatJSON 每个请求输出一行json
+	AccessLogFormatJSON AccessLogFormat = "json"
+	// AccessLogFormatLine 每个请求输出一行 key=value
+	AccessLogFormatLine AccessLogFormat = "line"
+)
+
+// accessLogKeys 固定字段的输出顺序
+var accessLogKeys = []string{
+	"method", "route", "path", "status", "code", "latency_ms", "bytes_in", "bytes_out",
+	"client_ip", "user_agent", "trace_id", "account_id", "error",
+}
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	// Format 输出格式，默认 line
+	Format AccessLogFormat
+	// Output 输出位置，默认 gin.DefaultWriter
+	Output io.Writer
+	// SkipPaths 不记录日志的路径，如健康检查
+	SkipPaths []string
+	// Fields 追加自定义字段，同名时覆盖固定字段
+	Fields func(c *gin.Context) map[string]interface{}
+}
+
+// AccessLog 结构化访问日志
+func AccessLog() gin.HandlerFunc {
+	return AccessLogWithConfig(AccessLogConfig{})
+}
+
+// AccessLogWithConfig 结构化访问日志，记录路由模板、状态码、业务code、请求和响应大小、耗时、客户端信息和trace id
+func AccessLogWithConfig(conf AccessLogConfig) gin.HandlerFunc {
+	if conf.Format == "" {
+		conf.Format = AccessLogFormatLine
+	}
+	if conf.Output == nil {
+		conf.Output = gin.DefaultWriter
+	}
+	skip := make(map[string]struct{}, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = struct{}{}
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if _, ok := skip[path]; ok {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		ctx := c.Request.Context()
+		clientIP := athCtx.GetClientIP(ctx)
+		if clientIP == "" {
+			clientIP = c.ClientIP()
+		}
+		bytesIn := c.Request.ContentLength
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+		bytesOut := c.Writer.Size()
+		if bytesOut < 0 {
+			bytesOut = 0
+		}
+
+		fields := map[string]interface{}{
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"path":       path,
+			"status":     c.Writer.Status(),
+			"code":       c.GetInt(extend.ResCodeKey),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes_in":   bytesIn,
+			"bytes_out":  bytesOut,
+			"client_ip":  clientIP,
+			"user_agent": c.Request.UserAgent(),
+			"trace_id":   athCtx.GetTraceId(ctx),
+			"account_id": tracing.SpanFromContext(ctx).AuthAccountID(),
+			"error":      c.Errors.ByType(gin.ErrorTypePrivate).String(),
+		}
+		if conf.Fields != nil {
+			for k, v := range conf.Fields(c) {
+				fields[k] = v
+			}
+		}
+
+		var line string
+		if conf.Format == AccessLogFormatJSON {
+			line = formatAccessLogJSON(start, fields)
+		} else {
+			line = formatAccessLogLine(start, fields)
+		}
+		if _, err := io.WriteString(conf.Output, line); err != nil {
+			log.Warnf("write access log failed: %s", err)
+		}
+	}
+}
+
+// formatAccessLogJSON 输出json格式
+func formatAccessLogJSON(start time.Time, fields map[string]interface{}) string {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["app"] = config.Get().App.Name
+	entry["type"] = "access"
+	entry["time"] = start.Format(log.DefaultTimestampFormat)
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Sprintf("{\"type\":\"access\",\"error\":%q}\n", err.Error())
+	}
+	return string(b) + "\n"
+}
+
+// formatAccessLogLine 与 LineFormatter 保持一致的前缀，后面按固定顺序输出 key=value，自定义字段按key排序追加
+func formatAccessLogLine(start time.Time, fields map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s [ACCESS] [%s] -", config.Get().App.Name, start.Format(log.DefaultTimestampFormat)))
+
+	written := make(map[string]struct{}, len(accessLogKeys))
+	for _, k := range accessLogKeys {
+		written[k] = struct{}{}
+		writeAccessLogField(&b, k, fields[k])
+	}
+	extra := make([]string, 0)
+	for k := range fields {
+		if _, ok := written[k]; !ok {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		writeAccessLogField(&b, k, fields[k])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeAccessLogField 含空格或引号的值加引号
+func writeAccessLogField(b *strings.Builder, key string, value interface{}) {
+	v := fmt.Sprint(value)
+	if v == "" {
+		v = "-"
+	}
+	if strings.ContainsAny(v, " \"=") {
+		v = fmt.Sprintf("%q", v)
+	}
+	b.WriteString(" ")
+	b.WriteString(key)
+	b.WriteString("=")
+	b.WriteString(v)
+}
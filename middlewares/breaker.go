@@ -0,0 +1,286 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BreakerState 熔断状态
+type BreakerState string
+
+// 熔断状态定义
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerConfig 熔断与隔板配置，按路由模板(c.FullPath())分别统计
+type BreakerConfig struct {
+	// MaxConcurrent 每个路由的最大并发数，0表示不限制
+	MaxConcurrent int
+	// ErrorRate 触发熔断的错误率，默认0.5
+	ErrorRate float64
+	// MinRequests 统计窗口内达到该请求数后才计算错误率，默认20
+	MinRequests int
+	// Window 错误率统计窗口，默认10秒
+	Window time.Duration
+	// OpenTimeout 熔断后进入半开状态的等待时间，默认30秒
+	OpenTimeout time.Duration
+	// HalfOpenProbes 半开状态放行的探测请求数，全部成功后关闭熔断，默认3
+	HalfOpenProbes int
+	// IsFailure 判断请求是否失败，默认响应状态码>=500或请求超时
+	IsFailure func(c *gin.Context) bool
+}
+
+// BreakerStats 路由熔断状态
+type BreakerStats struct {
+	Route      string       `json:"route"`
+	State      BreakerState `json:"state"`
+	Requests   int          `json:"requests"`
+	Failures   int          `json:"failures"`
+	Concurrent int          `json:"concurrent"`
+	Rejected   uint64       `json:"rejected"`
+}
+
+// CircuitBreaker 路由级别的熔断器和隔板
+type CircuitBreaker struct {
+	conf BreakerConfig
+
+	mu     sync.Mutex
+	routes map[string]*routeBreaker
+}
+
+// routeBreaker 单个路由的状态
+type routeBreaker struct {
+	route string
+	sem   chan struct{}
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	openedAt    time.Time
+	windowStart time.Time
+	requests    int
+	failures    int
+	probing     int
+	probeOK     int
+	rejected    uint64
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(conf BreakerConfig) *CircuitBreaker {
+	if conf.ErrorRate <= 0 {
+		conf.ErrorRate = 0.5
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = 20
+	}
+	if conf.Window <= 0 {
+		conf.Window = 10 * time.Second
+	}
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = 30 * time.Second
+	}
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = 3
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = func(c *gin.Context) bool {
+			return c.Writer.Status() >= http.StatusInternalServerError || c.GetBool(extend.ResTimeoutKey)
+		}
+	}
+	return &CircuitBreaker{conf: conf, routes: make(map[string]*routeBreaker)}
+}
+
+// Handler 熔断中间件，熔断或并发数超限时返回503
+func (b *CircuitBreaker) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rb := b.route(c.FullPath())
+
+		if rb.sem != nil {
+			select {
+			case rb.sem <- struct{}{}:
+				defer func() { <-rb.sem }()
+			default:
+				rb.reject()
+				extend.SendData(c, nil, errors.NewServiceUnavailableError("too many concurrent requests"))
+				c.Abort()
+				return
+			}
+		}
+
+		ticket, ok := rb.allow(&b.conf)
+		if !ok {
+			extend.SendData(c, nil, errors.NewServiceUnavailableError("circuit breaker is open"))
+			c.Abort()
+			return
+		}
+
+		// panic时按失败统计
+		failed := true
+		defer func() {
+			rb.record(&b.conf, ticket, failed)
+		}()
+		c.Next()
+		failed = b.conf.IsFailure(c)
+	}
+}
+
+// Stats 获取所有路由的熔断状态
+func (b *CircuitBreaker) Stats() []BreakerStats {
+	b.mu.Lock()
+	routes := make([]*routeBreaker, 0, len(b.routes))
+	for _, rb := range b.routes {
+		routes = append(routes, rb)
+	}
+	b.mu.Unlock()
+
+	stats := make([]BreakerStats, 0, len(routes))
+	for _, rb := range routes {
+		stats = append(stats, rb.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Route < stats[j].Route
+	})
+	return stats
+}
+
+// StatusHandler 输出熔断状态，用于注册状态查看接口
+func (b *CircuitBreaker) StatusHandler(c *gin.Context) {
+	extend.SendData(c, b.Stats(), nil)
+}
+
+func (b *CircuitBreaker) route(route string) *routeBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rb, ok := b.routes[route]
+	if !ok {
+		rb = &routeBreaker{route: route, state: BreakerClosed, windowStart: time.Now()}
+		if b.conf.MaxConcurrent > 0 {
+			rb.sem = make(chan struct{}, b.conf.MaxConcurrent)
+		}
+		b.routes[route] = rb
+	}
+	return rb
+}
+
+// breakerTicket 请求放行时的状态和状态代数
+type breakerTicket struct {
+	state      BreakerState
+	generation uint64
+}
+
+// allow 判断请求是否放行，返回放行时的状态
+func (rb *routeBreaker) allow(conf *BreakerConfig) (breakerTicket, bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := time.Now()
+	switch rb.state {
+	case BreakerClosed:
+		if now.Sub(rb.windowStart) >= conf.Window {
+			rb.resetWindow(now)
+		}
+		return rb.ticket(), true
+	case BreakerOpen:
+		if now.Sub(rb.openedAt) < conf.OpenTimeout {
+			rb.rejected++
+			return rb.ticket(), false
+		}
+		rb.transit(BreakerHalfOpen)
+		rb.probing, rb.probeOK = 0, 0
+	}
+
+	if rb.probing+rb.probeOK >= conf.HalfOpenProbes {
+		rb.rejected++
+		return rb.ticket(), false
+	}
+	rb.probing++
+	return rb.ticket(), true
+}
+
+// record 记录请求结果，ticket 为请求放行时的状态
+func (rb *routeBreaker) record(conf *BreakerConfig, ticket breakerTicket, failed bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	// 放行后状态已经变化的请求不再统计，避免上一轮的探测请求影响本轮的计数
+	if ticket.generation != rb.generation {
+		return
+	}
+	switch ticket.state {
+	case BreakerClosed:
+		rb.requests++
+		if failed {
+			rb.failures++
+		}
+		if rb.requests >= conf.MinRequests && float64(rb.failures)/float64(rb.requests) >= conf.ErrorRate {
+			rb.open()
+		}
+	case BreakerHalfOpen:
+		rb.probing--
+		if failed {
+			rb.open()
+			return
+		}
+		rb.probeOK++
+		if rb.probeOK >= conf.HalfOpenProbes {
+			rb.transit(BreakerClosed)
+			rb.resetWindow(time.Now())
+		}
+	}
+}
+
+func (rb *routeBreaker) reject() {
+	rb.mu.Lock()
+	rb.rejected++
+	rb.mu.Unlock()
+}
+
+func (rb *routeBreaker) open() {
+	rb.transit(BreakerOpen)
+	rb.openedAt = time.Now()
+}
+
+func (rb *routeBreaker) resetWindow(now time.Time) {
+	rb.windowStart = now
+	rb.requests, rb.failures = 0, 0
+}
+
+func (rb *routeBreaker) ticket() breakerTicket {
+	return breakerTicket{state: rb.state, generation: rb.generation}
+}
+
+// transit 切换状态并记录日志，每次切换状态代数加一，调用方需持有锁
+func (rb *routeBreaker) transit(to BreakerState) {
+	log.WarnFields(logrus.Fields{
+		"route":    rb.route,
+		"from":     rb.state,
+		"to":       to,
+		"requests": rb.requests,
+		"failures": rb.failures,
+	}, "Circuit breaker state changed")
+	rb.state = to
+	rb.generation++
+}
+
+func (rb *routeBreaker) stats() BreakerStats {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return BreakerStats{
+		Route:      rb.route,
+		State:      rb.state,
+		Requests:   rb.requests,
+		Failures:   rb.failures,
+		Concurrent: len(rb.sem),
+		Rejected:   rb.rejected,
+	}
+}
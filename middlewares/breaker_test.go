@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRouteBreaker() (*routeBreaker, *BreakerConfig) {
+	b := NewCircuitBreaker(BreakerConfig{
+		MinRequests:    2,
+		ErrorRate:      0.5,
+		OpenTimeout:    20 * time.Millisecond,
+		HalfOpenProbes: 2,
+	})
+	return b.route("/orders"), &b.conf
+}
+
+func TestRouteBreakerStateMachine(t *testing.T) {
+	rb, conf := newTestRouteBreaker()
+
+	for i := 0; i < 2; i++ {
+		ticket, ok := rb.allow(conf)
+		if !ok {
+			t.Fatal("closed breaker should allow requests")
+		}
+		rb.record(conf, ticket, true)
+	}
+	if rb.stats().State != BreakerOpen {
+		t.Fatalf("state = %s, want open", rb.stats().State)
+	}
+	if _, ok := rb.allow(conf); ok {
+		t.Fatal("open breaker should reject requests")
+	}
+
+	time.Sleep(conf.OpenTimeout)
+	p1, ok1 := rb.allow(conf)
+	p2, ok2 := rb.allow(conf)
+	if !ok1 || !ok2 || p1.state != BreakerHalfOpen {
+		t.Fatal("half-open breaker should allow probes")
+	}
+	if _, ok := rb.allow(conf); ok {
+		t.Fatal("half-open breaker should reject requests beyond HalfOpenProbes")
+	}
+
+	rb.record(conf, p1, false)
+	rb.record(conf, p2, false)
+	if rb.stats().State != BreakerClosed {
+		t.Fatalf("state = %s, want closed", rb.stats().State)
+	}
+}
+
+func TestRouteBreakerIgnoresStaleProbes(t *testing.T) {
+	rb, conf := newTestRouteBreaker()
+	rb.mu.Lock()
+	rb.open()
+	rb.mu.Unlock()
+
+	// 第一轮半开：一个探测失败重新熔断，另一个探测还没有返回
+	time.Sleep(conf.OpenTimeout)
+	failed, _ := rb.allow(conf)
+	stale, _ := rb.allow(conf)
+	rb.record(conf, failed, true)
+	if rb.stats().State != BreakerOpen {
+		t.Fatalf("state = %s, want open", rb.stats().State)
+	}
+
+	// 第二轮半开后，上一轮的探测才返回
+	time.Sleep(conf.OpenTimeout)
+	probe, ok := rb.allow(conf)
+	if !ok {
+		t.Fatal("half-open breaker should allow probes")
+	}
+	rb.record(conf, stale, false)
+	rb.mu.Lock()
+	probing, probeOK := rb.probing, rb.probeOK
+	rb.mu.Unlock()
+	if probing != 1 || probeOK != 0 {
+		t.Fatalf("probing = %d, probeOK = %d, want 1, 0", probing, probeOK)
+	}
+
+	second, ok := rb.allow(conf)
+	if !ok {
+		t.Fatal("stale probe should not take a probe slot")
+	}
+	if _, ok := rb.allow(conf); ok {
+		t.Fatal("half-open breaker should reject requests beyond HalfOpenProbes")
+	}
+	rb.record(conf, probe, false)
+	rb.record(conf, second, false)
+	if rb.stats().State != BreakerClosed {
+		t.Fatalf("state = %s, want closed", rb.stats().State)
+	}
+}
+
+func TestRouteBreakerIgnoresRequestsFromPreviousCycle(t *testing.T) {
+	rb, conf := newTestRouteBreaker()
+	slow, _ := rb.allow(conf)
+
+	rb.mu.Lock()
+	rb.open()
+	rb.transit(BreakerClosed)
+	rb.resetWindow(time.Now())
+	rb.mu.Unlock()
+
+	rb.record(conf, slow, true)
+	if got := rb.stats().Requests; got != 0 {
+		t.Fatalf("requests = %d, want 0", got)
+	}
+}
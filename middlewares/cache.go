@@ -0,0 +1,319 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/log"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HeaderCache 标记响应是否命中缓存
+const HeaderCache = "X-Cache"
+
+// CacheConfig 响应缓存配置
+type CacheConfig struct {
+	// Store 缓存存储，必须设置。进程内缓存使用 NewMemoryCacheStore 创建，服务退出时由调用方 Close
+	Store CacheStore
+	// TTL 缓存时间，默认1分钟
+	TTL time.Duration
+	// KeyPrefix 缓存key前缀
+	KeyPrefix string
+	// VaryHeaders 参与缓存key计算的请求头
+	VaryHeaders []string
+	// Tags 返回响应关联的标签，用于 CacheStore.InvalidateTags 批量失效，在handler执行后调用
+	Tags func(c *gin.Context) []string
+	// CacheAuthenticated 缓存带 Authorization、Cookie 或账号id的请求，默认跳过；
+	// 开启后这些请求头和账号id参与缓存key计算，每个用户使用单独的缓存
+	CacheAuthenticated bool
+}
+
+// cachedResponse 缓存的响应内容
+type cachedResponse struct {
+	Status       int         `json:"status"`
+	ContentType  string      `json:"contentType"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag"`
+	LastModified time.Time   `json:"lastModified"`
+}
+
+// uncachedHeaders 不缓存的响应头：逐跳header、每个请求不同的header和 Set-Cookie
+var uncachedHeaders = map[string]struct{}{
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+	"Set-Cookie":          {},
+	"Date":                {},
+	"Content-Length":      {},
+	"Content-Type":        {},
+	"Etag":                {},
+	"Last-Modified":       {},
+	HeaderCache:           {},
+	HeaderRequestID:       {},
+	HeaderTraceID:         {},
+}
+
+// Cache 响应缓存中间件
+func Cache(store CacheStore, ttl time.Duration) gin.HandlerFunc {
+	return CacheWithConfig(CacheConfig{Store: store, TTL: ttl})
+}
+
+// CacheWithConfig 缓存GET/HEAD请求中成功的 extend.Res 响应，
+// 并根据 If-None-Match / If-Modified-Since 返回304
+func CacheWithConfig(conf CacheConfig) gin.HandlerFunc {
+	if conf.Store == nil {
+		panic("middlewares: CacheConfig.Store is required")
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = time.Minute
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		authenticated := isAuthenticated(c)
+		if authenticated && !conf.CacheAuthenticated {
+			c.Next()
+			return
+		}
+		varyHeaders := conf.VaryHeaders
+		if authenticated {
+			varyHeaders = append(append([]string{}, varyHeaders...), authHeaders...)
+		}
+		key := conf.KeyPrefix + cacheKey(c, varyHeaders)
+
+		if data, ok, err := conf.Store.Get(ctx, key); err != nil {
+			log.ErrorWithTrace(ctx, err, "Cache load failed")
+		} else if ok {
+			resp := cachedResponse{}
+			if err := json.Unmarshal(data, &resp); err == nil {
+				c.Header(HeaderCache, "HIT")
+				writeCachedResponse(c, &resp)
+				c.Abort()
+				return
+			}
+		}
+
+		// 只缓存handler设置的header，之前的中间件设置的header每个请求都会重新设置
+		before := c.Writer.Header().Clone()
+		w := &cacheWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		body := w.body.Bytes()
+		if w.status != http.StatusOK || !isSuccessRes(body) {
+			c.Writer.WriteHeader(w.status)
+			c.Writer.Write(body)
+			return
+		}
+
+		// 缓存的内容不包含traceId，写出时替换为当前请求的traceId，ETag 不会因为traceId变化
+		body, ok := withoutTraceID(body)
+		if !ok {
+			c.Writer.WriteHeader(w.status)
+			c.Writer.Write(w.body.Bytes())
+			return
+		}
+		resp := &cachedResponse{
+			Status:       w.status,
+			ContentType:  c.Writer.Header().Get("Content-Type"),
+			Header:       handlerHeaders(before, c.Writer.Header()),
+			Body:         body,
+			ETag:         etag(body),
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		var tags []string
+		if conf.Tags != nil {
+			tags = conf.Tags(c)
+		}
+		if data, err := json.Marshal(resp); err == nil {
+			if err := conf.Store.Set(ctx, key, data, conf.TTL, tags...); err != nil {
+				log.ErrorWithTrace(ctx, err, "Cache save failed")
+			}
+		}
+
+		c.Header(HeaderCache, "MISS")
+		writeCachedResponse(c, resp)
+	}
+}
+
+// writeCachedResponse 写出缓存响应，满足条件请求时返回304
+func writeCachedResponse(c *gin.Context, resp *cachedResponse) {
+	c.Header("ETag", resp.ETag)
+	c.Header("Last-Modified", resp.LastModified.Format(http.TimeFormat))
+	if notModified(c.Request, resp.ETag, resp.LastModified) {
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	header := c.Writer.Header()
+	for k, v := range resp.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	if resp.ContentType != "" {
+		c.Header("Content-Type", resp.ContentType)
+	}
+	c.Writer.WriteHeader(resp.Status)
+	c.Writer.Write(withTraceID(resp.Body, athCtx.GetTraceId(c.Request.Context())))
+}
+
+// handlerHeaders handler新增或修改的响应头，不包含 uncachedHeaders
+func handlerHeaders(before, after http.Header) http.Header {
+	header := http.Header{}
+	for k, v := range after {
+		if _, ok := uncachedHeaders[k]; ok {
+			continue
+		}
+		if old, ok := before[k]; ok && strings.Join(old, "\n") == strings.Join(v, "\n") {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	return header
+}
+
+// authHeaders 标识用户身份的请求头
+var authHeaders = []string{"Authorization", "Cookie", tracing.HeaderAuthAccountID}
+
+// isAuthenticated 请求是否带有用户身份
+func isAuthenticated(c *gin.Context) bool {
+	for _, name := range authHeaders {
+		if c.GetHeader(name) != "" {
+			return true
+		}
+	}
+	return tracing.SpanFromContext(c.Request.Context()).AuthAccountID() != ""
+}
+
+// withoutTraceID 删除 extend.Res 中的traceId
+func withoutTraceID(body []byte) ([]byte, bool) {
+	res := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, false
+	}
+	delete(res, "traceId")
+	b, err := json.Marshal(res)
+	return b, err == nil
+}
+
+// withTraceID 写入当前请求的traceId
+func withTraceID(body []byte, traceID string) []byte {
+	if traceID == "" {
+		return body
+	}
+	res := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return body
+	}
+	res["traceId"], _ = json.Marshal(traceID)
+	b, err := json.Marshal(res)
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+// notModified If-None-Match 优先于 If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag = strings.TrimPrefix(etag, "W/")
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+// cacheKey 根据请求方法、路径、排序后的query和指定的请求头计算缓存key
+func cacheKey(c *gin.Context, varyHeaders []string) string {
+	h := sha1.New()
+	h.Write([]byte(c.Request.Method))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(c.Request.URL.Path))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(c.Request.URL.Query().Encode()))
+	for _, name := range varyHeaders {
+		h.Write([]byte{'\n'})
+		h.Write([]byte(name + ":" + c.GetHeader(name)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// etag 响应中的traceId每次请求都不同，使用弱ETag
+func etag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `W/"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// isSuccessRes 判断响应是否为成功的 extend.Res
+func isSuccessRes(body []byte) bool {
+	res := struct {
+		Success bool `json:"success"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return false
+	}
+	return res.Success
+}
+
+// cacheWriter 缓存handler的响应，由中间件决定最终写出的内容
+type cacheWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *cacheWriter) WriteHeaderNow() {}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *cacheWriter) Status() int {
+	return w.status
+}
+
+func (w *cacheWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *cacheWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *cacheWriter) Flush() {}
@@ -0,0 +1,238 @@
+package middlewares
+
+import (
+	"container/list"
+	"context"
+	goRedis "github.com/go-redis/redis/v8"
+	"sync"
+	"time"
+)
+
+// CacheStore 响应缓存存储
+type CacheStore interface {
+	// Get 获取缓存，不存在时返回false
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set 设置缓存并关联标签
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	// InvalidateTags 删除标签关联的所有缓存
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+type memoryCacheItem struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+	tags     []string
+}
+
+// MemoryCacheConfig 进程内缓存配置
+type MemoryCacheConfig struct {
+	// MaxEntries 最大缓存数量，超出时淘汰最久未使用的缓存，默认10000
+	MaxEntries int
+	// CleanupInterval 清理过期缓存的间隔，默认1分钟
+	CleanupInterval time.Duration
+}
+
+// MemoryCacheStore 进程内缓存，适合单实例或数据允许短暂不一致的场景，
+// 后台定时清理过期缓存，不再使用时调用 Close 停止清理
+type MemoryCacheStore struct {
+	conf MemoryCacheConfig
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	tags  map[string]map[string]struct{}
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMemoryCacheStore 使用默认配置创建进程内缓存
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return NewMemoryCacheStoreWithConfig(MemoryCacheConfig{})
+}
+
+// NewMemoryCacheStoreWithConfig 创建进程内缓存
+func NewMemoryCacheStoreWithConfig(conf MemoryCacheConfig) *MemoryCacheStore {
+	if conf.MaxEntries <= 0 {
+		conf.MaxEntries = 10000
+	}
+	if conf.CleanupInterval <= 0 {
+		conf.CleanupInterval = time.Minute
+	}
+	s := &MemoryCacheStore{
+		conf:  conf,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+		tags:  make(map[string]map[string]struct{}),
+		stop:  make(chan struct{}),
+	}
+	go s.janitor()
+	return s
+}
+
+// Get implement CacheStore
+func (s *MemoryCacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := e.Value.(*memoryCacheItem)
+	if time.Now().After(item.expireAt) {
+		s.remove(e)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(e)
+	return item.value, true, nil
+}
+
+// Set implement CacheStore
+func (s *MemoryCacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	item := &memoryCacheItem{key: key, value: value, expireAt: time.Now().Add(ttl), tags: tags}
+	s.items[key] = s.lru.PushFront(item)
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for s.lru.Len() > s.conf.MaxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// InvalidateTags implement CacheStore
+func (s *MemoryCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if e, ok := s.items[key]; ok {
+				s.remove(e)
+			}
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// Len 当前缓存数量，包含还没有清理的过期缓存
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close 停止后台清理
+func (s *MemoryCacheStore) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// janitor 定时清理过期缓存
+func (s *MemoryCacheStore) janitor() {
+	ticker := time.NewTicker(s.conf.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *MemoryCacheStore) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, e := range s.items {
+		if now.After(e.Value.(*memoryCacheItem).expireAt) {
+			s.remove(e)
+		}
+	}
+}
+
+// remove 删除缓存并从关联的标签中移除，调用方需持有锁
+func (s *MemoryCacheStore) remove(e *list.Element) {
+	item := e.Value.(*memoryCacheItem)
+	s.lru.Remove(e)
+	delete(s.items, item.key)
+	for _, tag := range item.tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
+
+// RedisCacheStore 基于redis的缓存，多实例共享
+type RedisCacheStore struct {
+	client *goRedis.Client
+	prefix string
+}
+
+// NewRedisCacheStore 创建redis缓存，prefix 为所有key的前缀
+func NewRedisCacheStore(client *goRedis.Client, prefix string) *RedisCacheStore {
+	return &RedisCacheStore{client: client, prefix: prefix}
+}
+
+// Get implement CacheStore
+func (s *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == goRedis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set implement CacheStore
+func (s *RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe goRedis.Pipeliner) error {
+		pipe.Set(ctx, s.prefix+key, value, ttl)
+		for _, tag := range tags {
+			tagKey := s.tagKey(tag)
+			pipe.SAdd(ctx, tagKey, s.prefix+key)
+			pipe.Expire(ctx, tagKey, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// InvalidateTags implement CacheStore
+func (s *RedisCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := s.tagKey(tag)
+		keys, err := s.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, tagKey)
+		if err := s.client.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisCacheStore) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/extend"
+)
+
+func TestMemoryCacheStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCacheStoreWithConfig(MemoryCacheConfig{MaxEntries: 2, CleanupInterval: 10 * time.Millisecond})
+	defer s.Close()
+
+	s.Set(ctx, "expired", []byte("1"), time.Millisecond, "users")
+	time.Sleep(50 * time.Millisecond)
+	s.mu.Lock()
+	n, tags := s.lru.Len(), len(s.tags)
+	s.mu.Unlock()
+	if n != 0 || tags != 0 {
+		t.Errorf("after cleanup: entries = %d, tags = %d, want 0, 0", n, tags)
+	}
+
+	// 超出 MaxEntries 时淘汰最久未使用的缓存
+	s.Set(ctx, "a", []byte("a"), time.Minute, "users")
+	s.Set(ctx, "b", []byte("b"), time.Minute)
+	s.Get(ctx, "a")
+	s.Set(ctx, "c", []byte("c"), time.Minute)
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Error("a should be kept")
+	}
+
+	s.InvalidateTags(ctx, "users")
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("a should be invalidated")
+	}
+	if s.Len() != 1 {
+		t.Errorf("entries = %d, want 1", s.Len())
+	}
+}
+
+func TestCache(t *testing.T) {
+	store := NewMemoryCacheStore()
+	defer store.Close()
+	calls := 0
+	r := gin.New()
+	r.Use(Trace(), CacheWithConfig(CacheConfig{Store: store}))
+	r.GET("/profile", func(c *gin.Context) {
+		calls++
+		extend.SendData(c, gin.H{"name": c.GetHeader("Authorization")}, nil)
+	})
+
+	do := func(auth string) (*httptest.ResponseRecorder, extend.Res) {
+		req := httptest.NewRequest(http.MethodGet, "/profile", http.NoBody)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		res := extend.Res{}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
+
+	w1, res1 := do("")
+	w2, res2 := do("")
+	if calls != 1 || w2.Header().Get(HeaderCache) != "HIT" {
+		t.Errorf("calls = %d, cache = %q, want a cache hit", calls, w2.Header().Get(HeaderCache))
+	}
+	if res1.TraceID == "" || res1.TraceID == res2.TraceID {
+		t.Errorf("traceId = %q, %q, want the traceId of each request", res1.TraceID, res2.TraceID)
+	}
+	if w1.Header().Get("ETag") == "" || w1.Header().Get("ETag") != w2.Header().Get("ETag") {
+		t.Errorf("ETag = %q, %q, want stable ETag", w1.Header().Get("ETag"), w2.Header().Get("ETag"))
+	}
+
+	// 带认证信息的请求默认不使用缓存
+	w3, res3 := do("Bearer alice")
+	if calls != 2 || w3.Header().Get(HeaderCache) != "" {
+		t.Errorf("authenticated request: calls = %d, cache = %q, want bypass", calls, w3.Header().Get(HeaderCache))
+	}
+	if data, _ := res3.Data.(map[string]interface{}); data["name"] != "Bearer alice" {
+		t.Errorf("authenticated request data = %v", res3.Data)
+	}
+}
+
+func TestCacheReplaysHeaders(t *testing.T) {
+	store := NewMemoryCacheStore()
+	defer store.Close()
+	r := gin.New()
+	r.Use(Trace(), Cache(store, time.Minute))
+	r.GET("/items", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=60")
+		c.Header("X-Total-Count", "3")
+		c.SetCookie("session", "secret", 60, "/", "", false, true)
+		extend.SendData(c, []int{1, 2, 3}, nil)
+	})
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", http.NoBody))
+		return w
+	}
+	miss, hit := do(), do()
+	if hit.Header().Get(HeaderCache) != "HIT" {
+		t.Fatalf("cache = %q, want HIT", hit.Header().Get(HeaderCache))
+	}
+	for _, name := range []string{"Cache-Control", "X-Total-Count", "Content-Type"} {
+		if miss.Header().Get(name) == "" || miss.Header().Get(name) != hit.Header().Get(name) {
+			t.Errorf("%s = %q, %q, want the same header on MISS and HIT", name, miss.Header().Get(name), hit.Header().Get(name))
+		}
+	}
+	if hit.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Set-Cookie = %q, want cookies not replayed", hit.Header().Get("Set-Cookie"))
+	}
+	if miss.Header().Get(HeaderTraceID) == hit.Header().Get(HeaderTraceID) {
+		t.Errorf("%s should be set by each request", HeaderTraceID)
+	}
+}
+
+func TestCacheRequiresStore(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("CacheWithConfig without Store should panic")
+		}
+	}()
+	CacheWithConfig(CacheConfig{})
+}
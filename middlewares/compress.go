@@ -0,0 +1,359 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	stdErrors "errors"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+// CompressConfig 响应压缩配置
+type CompressConfig struct {
+	// MinSize 小于该大小的响应不压缩，默认1024字节
+	MinSize int
+	// ContentTypes 需要压缩的响应类型前缀，默认json、xml、javascript和text
+	ContentTypes []string
+	// GzipLevel gzip压缩等级，默认 gzip.DefaultCompression
+	GzipLevel int
+	// BrotliLevel brotli压缩等级，默认 brotli.DefaultCompression
+	BrotliLevel int
+	// DecompressRequest 解压 Content-Encoding 为 gzip 或 br 的请求body。
+	// Compress 在 Trace 之后，Trace 记录的是压缩的请求body，需要记录原文时在 Trace 之前注册 Decompress，Default 会自动拆分
+	DecompressRequest bool
+	// MaxDecompressedBytes 解压后的请求body大小上限，超出返回413，默认10MB
+	MaxDecompressedBytes int64
+}
+
+// defaultMaxDecompressedBytes 解压后的请求body默认大小上限
+const defaultMaxDecompressedBytes = 10 << 20
+
+// errDecompressedTooLarge 解压后的请求body超过 MaxDecompressedBytes
+var errDecompressedTooLarge = stdErrors.New("decompressed request body too large")
+
+// Decompress 解压 Content-Encoding 为 gzip 或 br 的请求body，解压后超过 maxBytes 返回413，maxBytes 小于等于0时为10MB。
+// 需要在 BodyLimit 之后、Trace 之前注册，请求日志中记录的是解压后的内容
+func Decompress(maxBytes int64) gin.HandlerFunc {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxDecompressedBytes
+	}
+	return func(c *gin.Context) {
+		if !decompressOrAbort(c, maxBytes) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Compress 使用默认配置的响应压缩中间件
+func Compress() gin.HandlerFunc {
+	return CompressWithConfig(CompressConfig{DecompressRequest: true})
+}
+
+// CompressWithConfig 根据 Accept-Encoding 使用brotli或gzip压缩响应。
+// 需要在 Trace 之后注册，压缩发生在 Trace 记录响应内容之后，日志中记录的是原文；
+// 统计响应大小的 AccessLog 和 Recovery 需要在它之前注册
+func CompressWithConfig(conf CompressConfig) gin.HandlerFunc {
+	if conf.MinSize <= 0 {
+		conf.MinSize = 1024
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = []string{
+			"application/json",
+			"application/xml",
+			"application/javascript",
+			"text/",
+		}
+	}
+	if conf.GzipLevel == 0 {
+		conf.GzipLevel = gzip.DefaultCompression
+	}
+	if conf.BrotliLevel == 0 {
+		conf.BrotliLevel = brotli.DefaultCompression
+	}
+	if conf.MaxDecompressedBytes <= 0 {
+		conf.MaxDecompressedBytes = defaultMaxDecompressedBytes
+	}
+
+	return func(c *gin.Context) {
+		if conf.DecompressRequest && !decompressOrAbort(c, conf.MaxDecompressedBytes) {
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		// Trace 已经包装过writer时在其下层压缩，保证记录的响应是原文
+		var cw *compressWriter
+		bw, ok := c.Writer.(*responseBodyWriter)
+		if ok {
+			cw = newCompressWriter(bw.ResponseWriter, encoding, &conf)
+			bw.ResponseWriter = cw
+		} else {
+			cw = newCompressWriter(c.Writer, encoding, &conf)
+			c.Writer = cw
+		}
+
+		// panic时不写出缓存的内容，交给 Recovery 重新响应
+		finished := false
+		defer func() {
+			if finished {
+				cw.close()
+			}
+			if ok {
+				bw.ResponseWriter = cw.ResponseWriter
+			} else {
+				c.Writer = cw.ResponseWriter
+			}
+			if !finished && cw.decided {
+				// 已经写出压缩的header和部分内容，Recovery 写入的错误响应会破坏压缩流，记录panic后中断连接
+				if r := recover(); r != nil {
+					if r != http.ErrAbortHandler {
+						ctx := c.Request.Context()
+						log.ErrorMapWithTrace(ctx, Stack(3, r), "Program Panic, compressed response already started")
+						capturePanic(ctx, r)
+					}
+					panic(http.ErrAbortHandler)
+				}
+			}
+		}()
+
+		c.Next()
+		finished = true
+	}
+}
+
+// decompressOrAbort 解压请求body，失败时返回错误响应并返回false
+func decompressOrAbort(c *gin.Context, maxBytes int64) bool {
+	err := decompressRequest(c.Request, maxBytes)
+	if err == nil {
+		return true
+	}
+	if err == errDecompressedTooLarge {
+		extend.SendData(c, nil, errors.NewRequestEntityTooLargeError())
+	} else {
+		extend.SendData(c, nil, errors.NewBadRequestError("invalid request body encoding"))
+	}
+	c.Abort()
+	return false
+}
+
+// decompressRequest 解压请求body，最多读取 maxBytes+1 字节，超出时返回 errDecompressedTooLarge，
+// BodyLimit 只能限制压缩后的大小，这里避免很小的压缩包解压出超大的内容
+func decompressRequest(r *http.Request, maxBytes int64) error {
+	if r.Body == nil {
+		return nil
+	}
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case encodingGzip:
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		reader = gr
+	case encodingBrotli:
+		reader = brotli.NewReader(r.Body)
+	default:
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(reader, maxBytes+1))
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxBytes {
+		return errDecompressedTooLarge
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.Header.Del("Content-Encoding")
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	r.ContentLength = int64(len(body))
+	return nil
+}
+
+// negotiateEncoding 从 Accept-Encoding 中选择权重最高的编码，权重相同时优先brotli
+func negotiateEncoding(accept string) string {
+	var (
+		encoding string
+		best     float64
+	)
+	for _, part := range strings.Split(accept, ",") {
+		name, q := strings.TrimSpace(part), 1.0
+		if idx := strings.Index(name, ";"); idx >= 0 {
+			params := strings.TrimSpace(name[idx+1:])
+			name = strings.TrimSpace(name[:idx])
+			if strings.HasPrefix(params, "q=") {
+				v, err := strconv.ParseFloat(params[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+		name = strings.ToLower(name)
+		if name != encodingGzip && name != encodingBrotli {
+			continue
+		}
+		if q > best || (q == best && q > 0 && name == encodingBrotli) {
+			encoding, best = name, q
+		}
+	}
+	if best <= 0 {
+		return ""
+	}
+	return encoding
+}
+
+// compressWriter 缓存响应直到达到 MinSize 后再决定是否压缩
+type compressWriter struct {
+	gin.ResponseWriter
+	conf     *CompressConfig
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+func newCompressWriter(w gin.ResponseWriter, encoding string, conf *CompressConfig) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: w,
+		conf:           conf,
+		encoding:       encoding,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code > 0 && !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.decide()
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.conf.MinSize {
+			return len(b), nil
+		}
+		w.decide()
+		return len(b), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || len(w.buf) > 0
+}
+
+func (w *compressWriter) Flush() {
+	w.decide()
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 写出header，并根据已缓存的内容决定是否压缩
+func (w *compressWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.ResponseWriter.Header()
+	if w.shouldCompress(header) {
+		switch w.encoding {
+		case encodingBrotli:
+			w.encoder = brotli.NewWriterLevel(w.ResponseWriter, w.conf.BrotliLevel)
+		case encodingGzip:
+			if gw, err := gzip.NewWriterLevel(w.ResponseWriter, w.conf.GzipLevel); err == nil {
+				w.encoder = gw
+			}
+		}
+		if w.encoder != nil {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) > 0 {
+		w.Write(buf)
+	}
+}
+
+func (w *compressWriter) shouldCompress(header http.Header) bool {
+	if len(w.buf) < w.conf.MinSize || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, t := range w.conf.ContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// discard 丢弃还没有写出的内容，已经写出时返回false
+func (w *compressWriter) discard() bool {
+	if w.decided {
+		return false
+	}
+	w.buf = nil
+	w.status = http.StatusOK
+	return true
+}
+
+// close 写出剩余内容并结束压缩
+func (w *compressWriter) close() {
+	w.decide()
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
@@ -0,0 +1,110 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestCompressDecompressRequest(t *testing.T) {
+	r := gin.New()
+	r.Use(CompressWithConfig(CompressConfig{DecompressRequest: true, MaxDecompressedBytes: 1024}))
+	r.POST("/", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%d", len(body))
+	})
+
+	tests := []struct {
+		name       string
+		body       []byte
+		wantStatus int
+		wantBody   string
+	}{
+		{"small", gzipBytes(t, []byte(`{"name":"gin"}`)), http.StatusOK, "14"},
+		{"bomb", gzipBytes(t, make([]byte, 1<<20)), http.StatusRequestEntityTooLarge, ""},
+		{"invalid", []byte("not gzip"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus || (tt.wantBody != "" && w.Body.String() != tt.wantBody) {
+			t.Errorf("%s: status = %d, body = %q, want %d %q", tt.name, w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	compress := CompressWithConfig(CompressConfig{MinSize: 16})
+	// Recovery 在 Compress 外层或内层都不能把错误响应写入已经开始的压缩流
+	t.Run("recovery outside", func(t *testing.T) { testCompressPanic(t, Recovery(), compress) })
+	t.Run("recovery inside", func(t *testing.T) { testCompressPanic(t, compress, Recovery()) })
+}
+
+func testCompressPanic(t *testing.T, middlewares ...gin.HandlerFunc) {
+	r := gin.New()
+	r.Use(Trace())
+	r.Use(middlewares...)
+	r.GET("/buffered", func(c *gin.Context) {
+		c.Writer.Header().Set("Content-Type", "application/json")
+		c.Writer.WriteString(`{"partial":`)
+		panic("boom")
+	})
+	r.GET("/streamed", func(c *gin.Context) {
+		c.Writer.Header().Set("Content-Type", "application/json")
+		c.Writer.WriteString(`{"partial":"` + strings.Repeat("x", 64))
+		c.Writer.Flush()
+		panic("boom")
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, []byte, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err == nil && resp.Header.Get("Content-Encoding") == "gzip" {
+			var gr *gzip.Reader
+			if gr, err = gzip.NewReader(bytes.NewReader(body)); err == nil {
+				body, err = ioutil.ReadAll(gr)
+			}
+		}
+		return resp, body, err
+	}
+
+	// 还没有写出时丢弃缓存的内容，返回完整的错误响应
+	resp, body, err := get("/buffered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError || bytes.Contains(body, []byte("partial")) {
+		t.Errorf("buffered: status = %d, body = %s", resp.StatusCode, body)
+	}
+
+	// 已经写出压缩内容时中断连接
+	if _, body, err := get("/streamed"); err == nil {
+		t.Errorf("streamed: want connection aborted, got body %q", body)
+	}
+}
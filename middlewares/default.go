@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+)
+
+// 默认中间件栈中的中间件名称
+const (
+	StackBodyLimit  = "body_limit"
+	StackRequestID  = "request_id"
+	StackDecompress = "decompress"
+	StackTrace      = "trace"
+	StackRealIP     = "real_ip"
+	StackCompress   = "compress"
+	StackSentry     = "sentry"
+	StackTenant     = "tenant"
+	StackLogger     = "logger"
+	StackAccessLog  = "access_log"
+	StackRecovery   = "recovery"
+	StackSecure     = "secure"
+	StackCors       = "cors"
+	StackTimeout    = "timeout"
+)
+
+// DefaultOptions 默认中间件栈配置
+// Trace、Sentry、Logger、Recovery、Cors 默认开启，其余中间件配置不为空时开启
+type DefaultOptions struct {
+	DisableTrace    bool
+	DisableSentry   bool
+	DisableLogger   bool
+	DisableRecovery bool
+	DisableCors     bool
+
+	// Trace Trace 的配置
+	Trace TraceConfig
+	// Recovery Recovery 的配置
+	Recovery RecoveryConfig
+
+	BodyLimit *BodyLimitConfig
+	RequestID *RequestIDConfig
+	RealIP    *RealIPConfig
+	Tenant    *TenantConfig
+	AccessLog *AccessLogConfig
+	Compress  *CompressConfig
+	Secure    *SecureConfig
+	Timeout   *TimeoutConfig
+}
+
+// stackEntry 中间件栈中的一项
+type stackEntry struct {
+	name     string
+	requires []string
+	handler  gin.HandlerFunc
+}
+
+// Default 按固定顺序返回默认中间件栈，中间件之间的先后关系由固定顺序保证，
+// 只有缺少后无法工作的依赖才声明为 requires，依赖未开启时返回错误：
+// BodyLimit -> RequestID -> Decompress -> Trace -> RealIP -> Sentry -> Tenant -> Logger -> AccessLog -> Recovery -> Compress -> Secure -> Cors -> Timeout
+func Default(opts DefaultOptions) ([]gin.HandlerFunc, error) {
+	return buildStack(defaultEntries(opts))
+}
+
+// defaultEntries 按固定顺序返回开启的中间件
+func defaultEntries(opts DefaultOptions) []stackEntry {
+	var entries []stackEntry
+	add := func(enabled bool, name string, handler func() gin.HandlerFunc, requires ...string) {
+		if enabled {
+			entries = append(entries, stackEntry{name: name, requires: requires, handler: handler()})
+		}
+	}
+
+	// BodyLimit 需要在 Trace 读取请求体之前
+	add(opts.BodyLimit != nil, StackBodyLimit, func() gin.HandlerFunc { return BodyLimitWithConfig(*opts.BodyLimit) })
+	// RequestID 在 Trace 之前，Trace 复用它设置的请求id
+	add(opts.RequestID != nil, StackRequestID, func() gin.HandlerFunc { return RequestIDWithConfig(*opts.RequestID) })
+	// Compress 开启 DecompressRequest 时在 Trace 之前解压，请求日志记录的是解压后的内容
+	decompress := opts.Compress != nil && opts.Compress.DecompressRequest
+	add(decompress, StackDecompress, func() gin.HandlerFunc { return Decompress(opts.Compress.MaxDecompressedBytes) })
+	add(!opts.DisableTrace, StackTrace, func() gin.HandlerFunc { return TraceWithConfig(opts.Trace) })
+	add(opts.RealIP != nil, StackRealIP, func() gin.HandlerFunc { return RealIP(*opts.RealIP) })
+	// Sentry 在 Trace 之后，sentry scope 中记录请求id
+	add(!opts.DisableSentry, StackSentry, Sentry)
+	// Tenant 在 Trace 之后写入span，开启 Sentry 时在它之后才能设置sentry tag
+	add(opts.Tenant != nil, StackTenant, func() gin.HandlerFunc { return Tenant(*opts.Tenant) })
+	// Logger、AccessLog 在 Compress 外层，Compress 结束后才能统计压缩后的响应大小
+	add(!opts.DisableLogger, StackLogger, LoggerWithFormatter)
+	add(opts.AccessLog != nil, StackAccessLog, func() gin.HandlerFunc { return AccessLogWithConfig(*opts.AccessLog) })
+	// Recovery 在 Compress 外层，panic时 Compress 先丢弃缓存的内容，Recovery 再写出完整的错误响应
+	add(!opts.DisableRecovery, StackRecovery, func() gin.HandlerFunc { return RecoveryWithConfig(opts.Recovery) })
+	// Compress 在 Trace 包装的writer下层压缩，保证日志记录的是原文
+	add(opts.Compress != nil, StackCompress, func() gin.HandlerFunc {
+		conf := *opts.Compress
+		conf.DecompressRequest = false
+		return CompressWithConfig(conf)
+	})
+	add(opts.Secure != nil, StackSecure, func() gin.HandlerFunc { return SecureWithConfig(*opts.Secure) })
+	add(!opts.DisableCors, StackCors, Cors)
+	// Timeout 在最内层，超时响应仍会经过外层的中间件
+	add(opts.Timeout != nil, StackTimeout, func() gin.HandlerFunc { return TimeoutWithConfig(*opts.Timeout) })
+
+	return entries
+}
+
+// UseDefault 注册默认中间件栈，依赖缺失时panic，在启动阶段快速失败
+func UseDefault(r gin.IRoutes, opts DefaultOptions) {
+	handlers, err := Default(opts)
+	if err != nil {
+		panic(err)
+	}
+	r.Use(handlers...)
+}
+
+// buildStack 校验每个中间件依赖的中间件都已经在它之前注册
+func buildStack(entries []stackEntry) ([]gin.HandlerFunc, error) {
+	installed := make(map[string]bool, len(entries))
+	handlers := make([]gin.HandlerFunc, 0, len(entries))
+	for _, entry := range entries {
+		for _, require := range entry.requires {
+			if !installed[require] {
+				return nil, fmt.Errorf("middlewares: %s requires %s to be installed before it", entry.name, require)
+			}
+		}
+		installed[entry.name] = true
+		handlers = append(handlers, entry.handler)
+	}
+	return handlers, nil
+}
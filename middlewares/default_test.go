@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDefaultOrder(t *testing.T) {
+	opts := DefaultOptions{
+		BodyLimit: &BodyLimitConfig{},
+		RequestID: &RequestIDConfig{},
+		RealIP:    &RealIPConfig{},
+		Tenant:    &TenantConfig{},
+		AccessLog: &AccessLogConfig{},
+		Compress:  &CompressConfig{DecompressRequest: true},
+		Secure:    &SecureConfig{},
+		Timeout:   &TimeoutConfig{},
+	}
+	var names []string
+	for _, entry := range defaultEntries(opts) {
+		names = append(names, entry.name)
+	}
+	want := []string{
+		StackBodyLimit, StackRequestID, StackDecompress, StackTrace, StackRealIP, StackSentry, StackTenant,
+		StackLogger, StackAccessLog, StackRecovery, StackCompress, StackSecure, StackCors, StackTimeout,
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("order = %v, want %v", names, want)
+	}
+	if _, err := Default(opts); err != nil {
+		t.Errorf("Default() error = %v", err)
+	}
+}
+
+func TestDefaultRequires(t *testing.T) {
+	// 关闭 Trace、Sentry 时其他中间件仍然可以工作，不能因为可选的中间件未开启而启动失败
+	tests := []struct {
+		name string
+		opts DefaultOptions
+	}{
+		{"tenant without sentry", DefaultOptions{DisableSentry: true, Tenant: &TenantConfig{}}},
+		{"tenant without trace", DefaultOptions{DisableTrace: true, DisableSentry: true, Tenant: &TenantConfig{}}},
+		{"compress without trace", DefaultOptions{DisableTrace: true, Compress: &CompressConfig{DecompressRequest: true}}},
+		{"access log without trace", DefaultOptions{DisableTrace: true, AccessLog: &AccessLogConfig{}}},
+		{"minimal", DefaultOptions{DisableTrace: true, DisableSentry: true, DisableLogger: true}},
+	}
+	for _, tt := range tests {
+		if _, err := Default(tt.opts); err != nil {
+			t.Errorf("%s: Default() error = %v", tt.name, err)
+		}
+	}
+
+	noop := func(c *gin.Context) {}
+	_, err := buildStack([]stackEntry{{name: "a", requires: []string{"b"}, handler: noop}, {name: "b", handler: noop}})
+	if err == nil || !strings.Contains(err.Error(), "a requires b") {
+		t.Errorf("buildStack() error = %v, want missing dependency error", err)
+	}
+}
+
+func TestDefaultAccessLogBytesOut(t *testing.T) {
+	var out bytes.Buffer
+	handlers, err := Default(DefaultOptions{
+		DisableLogger: true,
+		AccessLog:     &AccessLogConfig{Format: AccessLogFormatJSON, Output: &out},
+		Compress:      &CompressConfig{MinSize: 16},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(handlers...)
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("gin-ext ", 64))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var entry struct {
+		BytesOut int `json:"bytes_out"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("access log %q: %v", out.String(), err)
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" || entry.BytesOut != w.Body.Len() {
+		t.Errorf("bytes_out = %d, want compressed size %d", entry.BytesOut, w.Body.Len())
+	}
+}
+
+func TestDefaultDecompressBeforeTrace(t *testing.T) {
+	handlers, err := Default(DefaultOptions{DisableLogger: true, Compress: &CompressConfig{DecompressRequest: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	// 在 Trace 之后检查请求body，Trace 记录的请求日志使用同一个body
+	handlers = append(handlers[:2:2], append([]gin.HandlerFunc{func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if string(body) != `{"name":"gin"}` || c.GetHeader("Content-Encoding") != "" {
+			t.Errorf("body after trace = %q, want decompressed body", body)
+		}
+	}}, handlers[2:]...)...)
+	r.Use(handlers...)
+	r.POST("/", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(gzipBytes(t, []byte(`{"name":"gin"}`))))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != `{"name":"gin"}` {
+		t.Errorf("body = %q, want decompressed body", w.Body.String())
+	}
+}
@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/flags"
+)
+
+// FeatureFlag 功能开关未开启时返回404，隐藏尚未发布的接口
+func FeatureFlag(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !flags.Enabled(c.Request.Context(), name) {
+			extend.SendData(c, nil, errors.NewErrNotFoundError())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+// GrayConfig 灰度配置
+type GrayConfig struct {
+	// Rule 灰度规则
+	Rule tracing.GrayRule
+	// TrustedProxies 可信上游的CIDR或IP，只有来自可信上游的 x-gray 才会沿用，
+	// 其他请求携带的 x-gray 会被忽略并按 Rule 重新计算
+	TrustedProxies []string
+}
+
+// Gray 判断请求是否在灰度中并标记到span，客户端携带的 x-gray 会被忽略。
+// 需要在 Trace 之后注册，handler 中通过 tracing.IsGray(ctx) 判断
+func Gray(rule tracing.GrayRule) gin.HandlerFunc {
+	return GrayWithConfig(GrayConfig{Rule: rule})
+}
+
+// GrayWithConfig 判断请求是否在灰度中并标记到span，可信上游已经标记过的请求沿用上游的结果
+func GrayWithConfig(conf GrayConfig) gin.HandlerFunc {
+	decider := tracing.NewGrayDecider(conf.Rule)
+	trusted, err := parseCIDRs(conf.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		span := tracing.SpanFromContext(c.Request.Context())
+		if span.Get(tracing.HeaderGray) == "" || !fromTrustedProxy(c.Request.RemoteAddr, trusted) {
+			span.SetGray(decider.Decide(span.AuthAccountID(), c.Query(tracing.QueryAccountHash)))
+			c.Request = c.Request.WithContext(span.ContextWithSpan(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
+
+// fromTrustedProxy 判断请求的直连地址是否为可信代理
+func fromTrustedProxy(remoteAddr string, trusted []*net.IPNet) bool {
+	if len(trusted) == 0 {
+		return false
+	}
+	return containsIP(trusted, net.ParseIP(remoteHost(remoteAddr)))
+}
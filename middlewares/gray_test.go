@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+func TestGrayHeaderTrust(t *testing.T) {
+	cases := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		want       bool
+	}{
+		{"client header ignored", "203.0.113.7:1234", "1", false},
+		{"trusted proxy header kept", "10.0.0.2:1234", "1", true},
+		{"trusted proxy without header", "10.0.0.2:1234", "", false},
+	}
+
+	r := gin.New()
+	var gray bool
+	r.Use(Trace(), GrayWithConfig(GrayConfig{TrustedProxies: []string{"10.0.0.0/8"}}))
+	r.GET("/", func(c *gin.Context) { gray = tracing.IsGray(c.Request.Context()) })
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.header != "" {
+				req.Header.Set(tracing.HeaderGray, tc.header)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			if gray != tc.want {
+				t.Errorf("gray = %v, want %v", gray, tc.want)
+			}
+		})
+	}
+}
+
+func TestGrayRecomputesUntrustedHeader(t *testing.T) {
+	r := gin.New()
+	var gray bool
+	r.Use(Trace(), Gray(tracing.GrayRule{Accounts: []string{"W00000000514"}}))
+	r.GET("/", func(c *gin.Context) { gray = tracing.IsGray(c.Request.Context()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(tracing.HeaderGray, "0")
+	req.Header.Set(tracing.HeaderAuthAccountID, "W00000000514")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if !gray {
+		t.Error("gray account should be recomputed even if the client sends x-gray: 0")
+	}
+}
@@ -0,0 +1,279 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stdErrors "errors"
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/hlhgogo/gin-ext/app"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	athRedis "github.com/hlhgogo/gin-ext/redis"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// HeaderIdempotencyKey 幂等键请求头
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 标记响应为重放结果
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// releaseLockScript 只释放自己持有的锁
+var releaseLockScript = goRedis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// IdempotencyClient 幂等中间件使用的redis命令，*redis.Client 和 *redis.ClusterClient 均已实现
+type IdempotencyClient interface {
+	goRedis.Scripter
+	Get(ctx context.Context, key string) *goRedis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goRedis.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *goRedis.BoolCmd
+}
+
+// IdempotencyConfig 幂等配置
+type IdempotencyConfig struct {
+	// Client 存储幂等记录的redis客户端，为空时使用 redis.DefaultClient()
+	Client IdempotencyClient
+	// Header 幂等键请求头，默认 Idempotency-Key
+	Header string
+	// KeyPrefix redis key前缀
+	KeyPrefix string
+	// Methods 需要保证幂等的请求方法，默认 POST
+	Methods []string
+	// LockTTL 首次请求处理中的加锁时间
+	LockTTL time.Duration
+	// TTL 首次请求响应结果的保存时间
+	TTL time.Duration
+	// MaxBodyBytes 计算请求指纹时读取的请求体上限，超出返回413，默认1MB
+	MaxBodyBytes int64
+}
+
+// idempotencyStoreTimeout 释放锁和保存响应结果的超时时间，不受请求ctx取消的影响
+const idempotencyStoreTimeout = 3 * time.Second
+
+// errIdempotencyBodyTooLarge 请求体超过 MaxBodyBytes
+var errIdempotencyBodyTooLarge = stdErrors.New("idempotency request body too large")
+
+// idempotencyRecord 首次请求的响应结果
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency 使用默认配置的幂等中间件
+func Idempotency() gin.HandlerFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{})
+}
+
+// IdempotencyWithConfig 相同幂等键和请求内容的重试直接返回首次请求的响应，
+// 请求内容不一致返回422，首次请求仍在处理中返回409
+func IdempotencyWithConfig(conf IdempotencyConfig) gin.HandlerFunc {
+	if conf.Header == "" {
+		conf.Header = HeaderIdempotencyKey
+	}
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "idempotency:"
+	}
+	if len(conf.Methods) == 0 {
+		conf.Methods = []string{http.MethodPost}
+	}
+	if conf.LockTTL <= 0 {
+		conf.LockTTL = time.Minute
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = 24 * time.Hour
+	}
+	if conf.MaxBodyBytes <= 0 {
+		conf.MaxBodyBytes = 1 << 20
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(conf.Header)
+		if key == "" || app.InArray(c.Request.Method, conf.Methods) < 0 {
+			c.Next()
+			return
+		}
+
+		cli := conf.Client
+		if cli == nil {
+			// 未加载时 DefaultClient 返回nil指针，不能直接赋值给接口
+			if def := athRedis.DefaultClient(); def != nil {
+				cli = def
+			}
+		}
+		if cli == nil {
+			log.WarnWithTrace(c.Request.Context(), "Idempotency skipped, redis client not loaded")
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		fingerprint, err := requestFingerprint(c, conf.MaxBodyBytes)
+		if err == errIdempotencyBodyTooLarge {
+			extend.SendData(c, nil, errors.NewRequestEntityTooLargeError())
+			c.Abort()
+			return
+		} else if err != nil {
+			extend.SendData(c, nil, errors.NewBadRequestError("read request body failed"))
+			c.Abort()
+			return
+		}
+		recordKey := conf.KeyPrefix + idempotencyScope(c, key)
+		lockKey := recordKey + ":lock"
+
+		if replayIdempotent(c, cli, recordKey, fingerprint) {
+			return
+		}
+
+		token := app.RandString(16)
+		locked, err := cli.SetNX(ctx, lockKey, token, conf.LockTTL).Result()
+		if err != nil {
+			log.ErrorWithTrace(ctx, err, "Idempotency lock failed")
+			c.Next()
+			return
+		}
+		if !locked {
+			extend.SendData(c, nil, errors.NewConflictError("request with the same idempotency key is in progress"))
+			c.Abort()
+			return
+		}
+		defer func() {
+			// 请求ctx取消或超时后仍要释放锁，否则重试需要等待 LockTTL
+			releaseCtx, cancel := context.WithTimeout(athCtx.Detach(ctx), idempotencyStoreTimeout)
+			defer cancel()
+			if err := releaseLockScript.Run(releaseCtx, cli, []string{lockKey}, token).Err(); err != nil && err != goRedis.Nil {
+				log.ErrorWithTrace(releaseCtx, err, "Idempotency release lock failed")
+			}
+		}()
+
+		// 加锁前首次请求可能刚好完成
+		if replayIdempotent(c, cli, recordKey, fingerprint) {
+			return
+		}
+
+		body := captureBody(c)
+		offset := body.Len()
+
+		c.Next()
+
+		// 服务端错误不保存，允许客户端重试
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      c.Writer.Header().Clone(),
+			Body:        body.Bytes()[offset:],
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			log.ErrorWithTrace(ctx, err, "Idempotency marshal record failed")
+			return
+		}
+		saveCtx, cancel := context.WithTimeout(athCtx.Detach(ctx), idempotencyStoreTimeout)
+		defer cancel()
+		if err := cli.Set(saveCtx, recordKey, data, conf.TTL).Err(); err != nil {
+			log.ErrorWithTrace(ctx, err, "Idempotency save record failed")
+		}
+	}
+}
+
+// replayIdempotent 存在首次请求的响应时重放或拒绝，返回是否已经处理
+func replayIdempotent(c *gin.Context, cli IdempotencyClient, recordKey, fingerprint string) bool {
+	ctx := c.Request.Context()
+	data, err := cli.Get(ctx, recordKey).Bytes()
+	if err != nil {
+		if err != goRedis.Nil {
+			log.ErrorWithTrace(ctx, err, "Idempotency load record failed")
+		}
+		return false
+	}
+
+	record := idempotencyRecord{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.ErrorWithTrace(ctx, err, "Idempotency unmarshal record failed")
+		return false
+	}
+
+	if record.Fingerprint != fingerprint {
+		extend.SendData(c, nil, errors.NewUnprocessableEntityError("idempotency key is reused with a different request"))
+		c.Abort()
+		return true
+	}
+
+	// 保留当前请求已经设置的header，如请求id
+	header := c.Writer.Header()
+	for k, v := range record.Header {
+		if _, ok := header[k]; !ok {
+			header[k] = v
+		}
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	c.Status(record.Status)
+	c.Writer.Write(record.Body)
+	c.Abort()
+	return true
+}
+
+// idempotencyScope 幂等键按租户、账号和路由隔离，不同调用方使用相同的键不会读取到对方的响应
+func idempotencyScope(c *gin.Context, key string) string {
+	ctx := c.Request.Context()
+	span := tracing.SpanFromContext(ctx)
+	tenantID := athCtx.GetTenantID(ctx)
+	if tenantID == "" {
+		tenantID = span.TenantID()
+	}
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+
+	h := sha256.New()
+	for _, part := range []string{tenantID, span.AuthAccountID(), c.Request.Method, route, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestFingerprint 根据请求方法、路径和body计算请求指纹，最多读取 maxBytes 字节的body
+func requestFingerprint(c *gin.Context, maxBytes int64) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		if err != nil {
+			return "", err
+		}
+		if int64(len(body)) > maxBytes {
+			return "", errIdempotencyBodyTooLarge
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(c.Request.URL.RequestURI()))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
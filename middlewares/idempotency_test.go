@@ -0,0 +1,242 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	stdErrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	goRedis "github.com/go-redis/redis/v8"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+// fakeIdempotencyClient 内存实现的 IdempotencyClient，忽略过期时间
+type fakeIdempotencyClient struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newFakeIdempotencyClient() *fakeIdempotencyClient {
+	return &fakeIdempotencyClient{data: map[string]string{}}
+}
+
+func (f *fakeIdempotencyClient) Get(_ context.Context, key string) *goRedis.StringCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.data[key]
+	if !ok {
+		return goRedis.NewStringResult("", goRedis.Nil)
+	}
+	return goRedis.NewStringResult(v, nil)
+}
+
+func (f *fakeIdempotencyClient) Set(_ context.Context, key string, value interface{}, _ time.Duration) *goRedis.StatusCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[key] = toString(value)
+	return goRedis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeIdempotencyClient) SetNX(_ context.Context, key string, value interface{}, _ time.Duration) *goRedis.BoolCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.data[key]; ok {
+		return goRedis.NewBoolResult(false, nil)
+	}
+	f.data[key] = toString(value)
+	return goRedis.NewBoolResult(true, nil)
+}
+
+// Eval 只支持 releaseLockScript
+func (f *fakeIdempotencyClient) Eval(_ context.Context, _ string, keys []string, args ...interface{}) *goRedis.Cmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.data[keys[0]] == toString(args[0]) {
+		delete(f.data, keys[0])
+		return goRedis.NewCmdResult(int64(1), nil)
+	}
+	return goRedis.NewCmdResult(int64(0), nil)
+}
+
+func (f *fakeIdempotencyClient) EvalSha(context.Context, string, []string, ...interface{}) *goRedis.Cmd {
+	return goRedis.NewCmdResult(nil, stdErrors.New("NOSCRIPT No matching script"))
+}
+
+func (f *fakeIdempotencyClient) ScriptExists(_ context.Context, hashes ...string) *goRedis.BoolSliceCmd {
+	return goRedis.NewBoolSliceResult(make([]bool, len(hashes)), nil)
+}
+
+func (f *fakeIdempotencyClient) ScriptLoad(context.Context, string) *goRedis.StringCmd {
+	return goRedis.NewStringResult("", nil)
+}
+
+func (f *fakeIdempotencyClient) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.data)
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v.(string)
+}
+
+func idempotentRequest(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set(HeaderIdempotencyKey, key)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	cli := newFakeIdempotencyClient()
+	calls := 0
+	r := gin.New()
+	r.Use(IdempotencyWithConfig(IdempotencyConfig{Client: cli}))
+	r.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Header("X-Order-Id", "1")
+		c.String(http.StatusCreated, "order %d", calls)
+	})
+
+	first := idempotentRequest(r, "key-1", `{"amount":1}`)
+	second := idempotentRequest(r, "key-1", `{"amount":1}`)
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get("X-Order-Id") != "1" || second.Header().Get(HeaderIdempotentReplayed) != "true" {
+		t.Errorf("replay header = %v", second.Header())
+	}
+	if first.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Error("first response should not be marked as replayed")
+	}
+	// 只剩响应记录，锁已释放
+	if cli.Len() != 1 {
+		t.Errorf("keys = %d, want 1", cli.Len())
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	var nested *httptest.ResponseRecorder
+	r := gin.New()
+	r.Use(IdempotencyWithConfig(IdempotencyConfig{Client: newFakeIdempotencyClient()}))
+	r.POST("/orders", func(c *gin.Context) {
+		// 首次请求持有锁时相同幂等键的重试
+		nested = idempotentRequest(r, "key-1", `{"amount":1}`)
+		c.Status(http.StatusCreated)
+	})
+
+	if w := idempotentRequest(r, "key-1", `{"amount":1}`); w.Code != http.StatusCreated {
+		t.Errorf("first status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if nested.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", nested.Code, http.StatusConflict)
+	}
+}
+
+func TestIdempotencyFingerprintMismatch(t *testing.T) {
+	calls := 0
+	r := gin.New()
+	r.Use(IdempotencyWithConfig(IdempotencyConfig{Client: newFakeIdempotencyClient()}))
+	r.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	idempotentRequest(r, "key-1", `{"amount":1}`)
+	if w := idempotentRequest(r, "key-1", `{"amount":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+}
+
+func TestIdempotencyServerErrorNotStored(t *testing.T) {
+	cli := newFakeIdempotencyClient()
+	calls := 0
+	r := gin.New()
+	r.Use(IdempotencyWithConfig(IdempotencyConfig{Client: cli}))
+	r.POST("/orders", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	if w := idempotentRequest(r, "key-1", `{"amount":1}`); w.Code != http.StatusInternalServerError {
+		t.Errorf("first status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if cli.Len() != 0 {
+		t.Errorf("keys = %d, want 0", cli.Len())
+	}
+	w := idempotentRequest(r, "key-1", `{"amount":1}`)
+	if w.Code != http.StatusCreated || w.Header().Get(HeaderIdempotentReplayed) != "" {
+		t.Errorf("retry = %d replayed=%q, want %d executed", w.Code, w.Header().Get(HeaderIdempotentReplayed), http.StatusCreated)
+	}
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	scope := func(path, account, tenant string) string {
+		var got string
+		r := gin.New()
+		r.Use(Trace())
+		r.POST("/orders", func(c *gin.Context) { got = idempotencyScope(c, "key-1") })
+		r.POST("/refunds", func(c *gin.Context) { got = idempotencyScope(c, "key-1") })
+		req := httptest.NewRequest(http.MethodPost, path, http.NoBody)
+		req.Header.Set(tracing.HeaderAuthAccountID, account)
+		req.Header.Set(tracing.HeaderAuthTenantID, tenant)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	base := scope("/orders", "alice", "acme")
+	if base != scope("/orders", "alice", "acme") {
+		t.Error("same caller and route should use the same record")
+	}
+	for name, other := range map[string]string{
+		"account": scope("/orders", "bob", "acme"),
+		"tenant":  scope("/orders", "alice", "globex"),
+		"route":   scope("/refunds", "alice", "acme"),
+	} {
+		if other == base {
+			t.Errorf("different %s should use a different record", name)
+		}
+	}
+}
+
+func TestRequestFingerprintLimit(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"amount":1}`))
+	if _, err := requestFingerprint(c, 1024); err != nil {
+		t.Fatal(err)
+	}
+	// 计算指纹后handler仍能读取完整的请求体
+	body := new(bytes.Buffer)
+	body.ReadFrom(c.Request.Body)
+	if body.String() != `{"amount":1}` {
+		t.Errorf("body = %q", body.String())
+	}
+
+	c.Request = httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(make([]byte, 2048)))
+	if _, err := requestFingerprint(c, 1024); err != errIdempotencyBodyTooLarge {
+		t.Errorf("error = %v, want %v", err, errIdempotencyBodyTooLarge)
+	}
+}
@@ -0,0 +1,251 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RealIPConfig 客户端IP解析配置
+type RealIPConfig struct {
+	// TrustedProxies 可信代理的CIDR或IP，只有来自可信代理的 X-Forwarded-For / X-Real-IP 才会被采用
+	TrustedProxies []string
+}
+
+// RealIP 根据可信代理解析客户端真实IP，保存到 context.CtxValue 中，通过 athCtx.GetClientIP 获取
+func RealIP(conf RealIPConfig) gin.HandlerFunc {
+	trusted, err := parseCIDRs(conf.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		ip := resolveClientIP(c.Request.RemoteAddr, c.GetHeader("X-Forwarded-For"), c.GetHeader("X-Real-IP"), trusted)
+
+		commonValue := make(map[athCtx.CtxValueCommonKey]string)
+		cv := athCtx.GetCtxValue(c.Request.Context())
+		if cv != nil {
+			commonValue = cv.GetCommonValue()
+			commonValue[athCtx.CtxValueCommonKeyClientIP] = ip
+		}
+		athValue := athCtx.GetCtxValue(c.Request.Context())
+		athValue = athValue.SetCommonValue(commonValue)
+		athContext, _ := athCtx.SetCtxValue(c.Request.Context(), athValue)
+		c.Request = c.Request.WithContext(athContext)
+
+		c.Next()
+	}
+}
+
+// resolveClientIP 从右向左跳过可信代理，第一个不可信的地址即为客户端IP
+func resolveClientIP(remoteAddr, forwardedFor, realIP string, trusted []*net.IPNet) string {
+	remote := remoteHost(remoteAddr)
+	if !containsIP(trusted, net.ParseIP(remote)) {
+		return remote
+	}
+
+	if forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				break
+			}
+			client = hop
+			if !containsIP(trusted, ip) {
+				break
+			}
+		}
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// remoteHost 去掉 RemoteAddr 中的端口
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// IPFilterRules 黑白名单规则，支持CIDR和单个IP
+type IPFilterRules struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// IPFilter IP黑白名单，命中黑名单拒绝，白名单不为空时只允许白名单内的IP
+type IPFilter struct {
+	mu    sync.RWMutex
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter 创建IP黑白名单
+func NewIPFilter(rules IPFilterRules) (*IPFilter, error) {
+	f := &IPFilter{}
+	if err := f.Update(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// NewIPFilterFromFile 从json文件创建IP黑白名单
+func NewIPFilterFromFile(path string) (*IPFilter, error) {
+	rules, err := loadIPFilterRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewIPFilter(rules)
+}
+
+// Update 替换黑白名单规则
+func (f *IPFilter) Update(rules IPFilterRules) error {
+	allow, err := parseCIDRs(rules.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseCIDRs(rules.Deny)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.allow, f.deny = allow, deny
+	f.mu.Unlock()
+	return nil
+}
+
+// WatchFile 定时检查文件修改时间，文件变化后重新加载规则，返回停止函数
+func (f *IPFilter) WatchFile(path string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().After(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+			rules, err := loadIPFilterRules(path)
+			if err == nil {
+				err = f.Update(rules)
+			}
+			if err != nil {
+				log.Errorf("IP filter reload %s failed: %s", path, err)
+				continue
+			}
+			log.Infof("IP filter reloaded from %s", path)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Allowed 判断IP是否允许访问
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// Handler IP黑白名单中间件，需要在 RealIP 之后注册，
+// 没有注册 RealIP 时使用直连地址，不信任客户端传入的 X-Forwarded-For
+func (f *IPFilter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := athCtx.GetClientIP(c.Request.Context())
+		if ip == "" {
+			ip = remoteHost(c.Request.RemoteAddr)
+		}
+		if !f.Allowed(net.ParseIP(ip)) {
+			log.WarnWithTrace(c.Request.Context(), "IP %s is not allowed to access %s", ip, c.Request.URL.Path)
+			extend.SendData(c, nil, errors.NewForbiddenError())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func loadIPFilterRules(path string) (IPFilterRules, error) {
+	rules := IPFilterRules{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	err = json.Unmarshal(data, &rules)
+	return rules, err
+}
+
+// parseCIDRs 解析CIDR列表，单个IP按 /32 或 /128 处理
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", cidr)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		want         string
+	}{
+		{"direct client", "203.0.113.7:1234", "", "", "203.0.113.7"},
+		{"spoofed xff from untrusted peer", "203.0.113.7:1234", "198.51.100.1", "", "203.0.113.7"},
+		{"spoofed x-real-ip from untrusted peer", "203.0.113.7:1234", "", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"trusted proxy chain", "10.0.0.2:1234", "198.51.100.1, 192.168.1.1, 10.0.0.3", "", "198.51.100.1"},
+		{"spoofed hop before untrusted client", "10.0.0.2:1234", "1.2.3.4, 198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"invalid hop", "10.0.0.2:1234", "garbage, 10.0.0.3", "", "10.0.0.3"},
+		{"x-real-ip from trusted proxy", "10.0.0.2:1234", "", "198.51.100.1", "198.51.100.1"},
+		{"invalid x-real-ip", "10.0.0.2:1234", "", "not-an-ip", "10.0.0.2"},
+		{"remote addr without port", "203.0.113.7", "", "", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveClientIP(tt.remoteAddr, tt.forwardedFor, tt.realIP, trusted); got != tt.want {
+				t.Errorf("resolveClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilterAllowed(t *testing.T) {
+	f, err := NewIPFilter(IPFilterRules{Allow: []string{"10.0.0.0/8", "2001:db8::/32"}, Deny: []string{"10.0.0.66"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.0.0.66", false},
+		{"2001:db8::1", true},
+		{"203.0.113.7", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := f.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	denyOnly, err := NewIPFilter(IPFilterRules{Deny: []string{"203.0.113.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denyOnly.Allowed(net.ParseIP("203.0.113.7")) || !denyOnly.Allowed(net.ParseIP("198.51.100.1")) {
+		t.Error("empty allow list should allow every ip that is not denied")
+	}
+
+	if _, err := NewIPFilter(IPFilterRules{Allow: []string{"not-an-ip"}}); err == nil {
+		t.Error("invalid rule should fail")
+	}
+}
+
+func TestIPFilterHandler(t *testing.T) {
+	f, err := NewIPFilter(IPFilterRules{Deny: []string{"203.0.113.7"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		realIP     bool
+		remoteAddr string
+		want       int
+	}{
+		{"spoofed xff without RealIP", false, "203.0.113.7:1234", http.StatusForbidden},
+		{"spoofed xff with RealIP", true, "203.0.113.7:1234", http.StatusForbidden},
+		{"trusted proxy with RealIP", true, "10.0.0.2:1234", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if tt.realIP {
+				r.Use(RealIP(RealIPConfig{TrustedProxies: []string{"10.0.0.0/8"}}))
+			}
+			r.Use(f.Handler())
+			r.GET("/", func(c *gin.Context) {
+				c.String(http.StatusOK, athCtx.GetClientIP(c.Request.Context()))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "198.51.100.1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -22,12 +22,16 @@ func LoggerWithFormatter() gin.HandlerFunc {
 		}
 
 		requestId := athCtx.GetTraceId(param.Request.Context())
+		clientIP := athCtx.GetClientIP(param.Request.Context())
+		if clientIP == "" {
+			clientIP = param.ClientIP
+		}
 
 		return fmt.Sprintf("%s [INFO] [%14s] [%13s] %5s | %s %-7s %s | %5s | %s %3d %s | %3s | %5s %s\n",
 			config.Get().App.Name,
 			param.TimeStamp.Format("2006-01-02 15:04:05"),
 			requestId,
-			param.ClientIP,
+			clientIP,
 			methodColor, param.Method, resetColor,
 			param.Request.Proto,
 			statusColor, param.StatusCode, resetColor,
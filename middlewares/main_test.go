@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/config"
+	"github.com/hlhgogo/gin-ext/log"
+)
+
+// TestMain 在临时目录写入 config.json 后初始化配置和日志，SendData 等依赖它们
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "middlewares-test")
+	if err != nil {
+		panic(err)
+	}
+	conf := `{"app": {"name": "middlewares-test"}, "logger": {"level": "fatal"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(conf), 0644); err != nil {
+		panic(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	config.InitConfig()
+	log.Setup()
+	gin.SetMode(gin.TestMode)
+
+	code := m.Run()
+	os.Chdir(wd)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/flags"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaintenanceConfig 维护模式配置
+type MaintenanceConfig struct {
+	// RetryAfter 返回给客户端的 Retry-After，默认5分钟
+	RetryAfter time.Duration
+	// BypassAccounts 维护期间仍可访问的账号
+	BypassAccounts []string
+	// Flag 功能开关名，不为空时开关的 Enabled 打开即进入全局维护模式，可以通过文件或redis切换
+	Flag string
+}
+
+// Maintenance 维护模式，可以对整个服务或指定路由开启
+type Maintenance struct {
+	conf   MaintenanceConfig
+	bypass map[string]struct{}
+
+	mu     sync.RWMutex
+	global bool
+	routes map[string]struct{}
+}
+
+// NewMaintenance 创建维护模式
+func NewMaintenance(conf MaintenanceConfig) *Maintenance {
+	if conf.RetryAfter <= 0 {
+		conf.RetryAfter = 5 * time.Minute
+	}
+	bypass := make(map[string]struct{}, len(conf.BypassAccounts))
+	for _, account := range conf.BypassAccounts {
+		bypass[account] = struct{}{}
+	}
+	return &Maintenance{conf: conf, bypass: bypass, routes: map[string]struct{}{}}
+}
+
+// Enable 开启维护模式，不传路由时对整个服务开启，路由为 c.FullPath() 的模板
+func (m *Maintenance) Enable(routes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(routes) == 0 {
+		m.global = true
+		return
+	}
+	for _, route := range routes {
+		m.routes[route] = struct{}{}
+	}
+}
+
+// Disable 关闭维护模式，不传路由时关闭全部
+func (m *Maintenance) Disable(routes ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(routes) == 0 {
+		m.global = false
+		m.routes = map[string]struct{}{}
+		return
+	}
+	for _, route := range routes {
+		delete(m.routes, route)
+	}
+}
+
+// Active 判断路由是否处于维护中
+func (m *Maintenance) Active(route string) bool {
+	if m.conf.Flag != "" {
+		if flag, ok := flags.Get(m.conf.Flag); ok && flag.Enabled {
+			return true
+		}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.global {
+		return true
+	}
+	_, ok := m.routes[route]
+	return ok
+}
+
+// Handler 维护模式中间件，维护中返回503和 Retry-After
+func (m *Maintenance) Handler() gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(m.conf.RetryAfter / time.Second))
+	return func(c *gin.Context) {
+		if !m.Active(c.FullPath()) {
+			c.Next()
+			return
+		}
+		accountID := tracing.SpanFromContext(c.Request.Context()).AuthAccountID()
+		if _, ok := m.bypass[accountID]; ok && accountID != "" {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", retryAfter)
+		extend.SendData(c, nil, errors.NewServiceUnavailableError("service is under maintenance"))
+		c.Abort()
+	}
+}
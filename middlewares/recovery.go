@@ -2,14 +2,22 @@ package middlewares
 
 import (
 	"bytes"
+	"context"
+	stdErrors "errors"
 	"fmt"
+	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
 	"github.com/hlhgogo/gin-ext/errors"
 	"github.com/hlhgogo/gin-ext/extend"
 	"github.com/hlhgogo/gin-ext/log"
+	"net"
+	"net/http"
+	"os"
 	"runtime"
 	"runtime/debug"
 	"strings"
+	"syscall"
 )
 
 // PageNotFound 404PageHandle
@@ -17,27 +25,139 @@ func PageNotFound(c *gin.Context) {
 	extend.SendData(c, nil, errors.NewErrNotFoundError())
 }
 
+// RecoveryConfig panic恢复配置
+type RecoveryConfig struct {
+	// PanicHandler 把panic的值转换为返回给客户端的错误，为空或返回nil时error原样返回，其他类型返回 Unknown error
+	PanicHandler func(c *gin.Context, r interface{}) error
+}
+
 // Recovery http request exception recovery
 func Recovery() gin.HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryWithConfig 恢复panic并记录error日志、上报sentry。
+// http.ErrAbortHandler 会重新panic交给 net/http 中断连接，客户端断开的连接不再写入响应
+func RecoveryWithConfig(conf RecoveryConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				stk := Stack(3, err)
-				c.Set("Stack", stk)
-				log.InfoMapWithTrace(c, stk, "Program Panic")
-				switch err := err.(type) {
-				case error:
-					extend.SendData(c, nil, err)
-				default:
-					resErr := errors.NewErr("Unknown error")
-					extend.SendData(c, nil, resErr)
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			ctx := c.Request.Context()
+			stk := Stack(3, r)
+			if file, line := panicCaller(); file != "" && stk != nil {
+				stk["error_file"] = file
+				stk["error_line"] = line
+			}
+			c.Set("Stack", stk)
+
+			if err, ok := r.(error); ok && isBrokenConnection(err) {
+				log.WarnFieldsWithTrace(ctx, stk, "Program Panic, connection is broken")
+				c.Error(err)
+				c.Abort()
+				return
+			}
+
+			log.ErrorMapWithTrace(ctx, stk, "Program Panic")
+			capturePanic(ctx, r)
+			c.Set(extend.SentryCapturedKey, true)
+
+			if !discardResponse(c.Writer) {
+				// 压缩的响应已经写出了一部分，无法再写出完整的错误响应，中断连接
+				panic(http.ErrAbortHandler)
+			}
+
+			var err error
+			if conf.PanicHandler != nil {
+				err = conf.PanicHandler(c, r)
+			}
+			if err == nil {
+				if e, ok := r.(error); ok {
+					err = e
+				} else {
+					err = errors.NewErr("Unknown error")
 				}
 			}
+			extend.SendData(c, nil, err)
+			c.Abort()
 		}()
 		c.Next()
 	}
 }
 
+// discardResponse 丢弃panic前缓存的响应内容，Compress 已经写出压缩内容时返回false
+func discardResponse(w gin.ResponseWriter) bool {
+	switch w := w.(type) {
+	case *responseBodyWriter:
+		w.body.Reset()
+		return discardResponse(w.ResponseWriter)
+	case *compressWriter:
+		return w.discard()
+	default:
+		return true
+	}
+}
+
+// capturePanic 上报panic的值和完整的goroutine堆栈
+func capturePanic(ctx context.Context, r interface{}) {
+	hub := athCtx.GetCtxValue(ctx).GetSentryHub()
+	if hub == nil {
+		return
+	}
+	err, ok := r.(error)
+	if !ok {
+		// 非error的panic转换为error，sentry才会附带堆栈
+		err = fmt.Errorf("panic: %v", r)
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetExtra("panic", fmt.Sprintf("%#v", r))
+		scope.SetExtra("goroutine_stack", string(debug.Stack()))
+		hub.RecoverWithContext(ctx, err)
+	})
+}
+
+// panicCaller 找到触发panic的位置，需要在defer中调用
+func panicCaller() (string, int) {
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	afterPanic := false
+	for {
+		frame, more := frames.Next()
+		if afterPanic && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.File, frame.Line
+		}
+		if frame.Function == "runtime.gopanic" {
+			afterPanic = true
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// isBrokenConnection 客户端已经断开连接，无需再写入响应
+func isBrokenConnection(err error) bool {
+	if stdErrors.Is(err, syscall.EPIPE) || stdErrors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var ne *net.OpError
+	if stdErrors.As(err, &ne) {
+		var se *os.SyscallError
+		if stdErrors.As(ne.Err, &se) {
+			msg := strings.ToLower(se.Error())
+			return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+		}
+	}
+	return false
+}
+
 // Stack get stack
 func Stack(skip int, r interface{}) map[string]interface{} {
 
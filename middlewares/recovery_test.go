@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+)
+
+func TestRecoveryPanicHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     func(c *gin.Context, r interface{}) error
+		panicValue  interface{}
+		wantStatus  int
+		wantMessage string
+	}{
+		{"default", nil, "boom", http.StatusInternalServerError, "Unknown error"},
+		{"mapped", func(c *gin.Context, r interface{}) error {
+			return errors.NewBadRequestError(fmt.Sprint(r))
+		}, "bad input", http.StatusBadRequest, "bad input"},
+		{"mapper returns nil", func(c *gin.Context, r interface{}) error {
+			return nil
+		}, "boom", http.StatusInternalServerError, "Unknown error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(RecoveryWithConfig(RecoveryConfig{PanicHandler: tt.handler}))
+			r.GET("/", func(c *gin.Context) { panic(tt.panicValue) })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			var res extend.Res
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("body = %s: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.wantStatus || res.Success || res.Msg != tt.wantMessage {
+				t.Errorf("status = %d, res = %+v, want %d failed response %q", w.Code, res, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	r := gin.New()
+	r.Use(Recovery())
+	r.GET("/", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", got)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("http.ErrAbortHandler should be re-panicked")
+}
+
+func TestRecoveryBrokenConnection(t *testing.T) {
+	brokenPipe := &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}
+
+	r := gin.New()
+	var errs []*gin.Error
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		errs = c.Errors
+	}, Recovery())
+	r.GET("/", func(c *gin.Context) { panic(brokenPipe) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %s, want nothing written to a broken connection", w.Body.String())
+	}
+	if len(errs) != 1 || errs[0].Err != brokenPipe {
+		t.Errorf("errors = %v, want the broken pipe error", errs)
+	}
+}
+
+func TestIsBrokenConnection(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EPIPE, true},
+		{fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, true},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{fmt.Errorf("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isBrokenConnection(tt.err); got != tt.want {
+			t.Errorf("isBrokenConnection(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
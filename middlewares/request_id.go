@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/app"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+// HeaderRequestID 默认的请求id header
+const HeaderRequestID = "X-Request-Id"
+
+// RequestIDConfig 请求id配置
+type RequestIDConfig struct {
+	// RequestHeader 读取客户端请求id的header，默认 X-Request-Id
+	RequestHeader string
+	// ResponseHeader 返回请求id的header，默认 X-Request-Id
+	ResponseHeader string
+	// Generator 生成请求id，默认 app.NewUUIDv4
+	Generator app.IDGenerator
+	// Validator 校验客户端传入的请求id，校验失败时重新生成，默认 ValidRequestID
+	Validator func(id string) bool
+}
+
+// RequestID 请求id
+func RequestID() gin.HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+// RequestIDWithConfig 读取或生成请求id，写入 CtxValue 的 traceId 和 tracing.Span 的 x-request-id，
+// 保证日志、响应header和下游请求中的请求id一致
+func RequestIDWithConfig(conf RequestIDConfig) gin.HandlerFunc {
+	if conf.RequestHeader == "" {
+		conf.RequestHeader = HeaderRequestID
+	}
+	if conf.ResponseHeader == "" {
+		conf.ResponseHeader = HeaderRequestID
+	}
+	if conf.Generator == nil {
+		conf.Generator = app.NewUUIDv4
+	}
+	if conf.Validator == nil {
+		conf.Validator = ValidRequestID
+	}
+
+	return func(c *gin.Context) {
+		id := c.Request.Header.Get(conf.RequestHeader)
+		if id == "" || !conf.Validator(id) {
+			id = conf.Generator()
+		}
+		// 请求日志中的header与span使用同一个id
+		c.Request.Header.Set(tracing.HeaderRequestID, id)
+
+		span, _ := tracing.Extract(c.Request)
+		span.Set(tracing.HeaderRequestID, id)
+		c.Request = c.Request.WithContext(span.ContextWithSpan(c.Request.Context()))
+
+		athValue := athCtx.GetCtxValue(c.Request.Context())
+		commonValue := athValue.GetCommonValue()
+		commonValue[athCtx.CtxValueCommonKeyTraceID] = id
+		athContext, _ := athCtx.SetCtxValue(c.Request.Context(), athValue.SetCommonValue(commonValue))
+		c.Request = c.Request.WithContext(athContext)
+
+		c.Header(conf.ResponseHeader, id)
+		c.Next()
+	}
+}
+
+// ValidRequestID 请求id长度不超过128，只能包含字母、数字和 -_.:
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"0af7651916cd43dd", true},
+		{"req-1_2.3:4", true},
+		{strings.Repeat("a", 128), true},
+		{"", false},
+		{strings.Repeat("a", 129), false},
+		{"id with space", false},
+		{"id\nInjected: 1", false},
+		{"<script>", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestIDResponseHeader(t *testing.T) {
+	r := gin.New()
+	var traceID string
+	r.Use(RequestIDWithConfig(RequestIDConfig{ResponseHeader: "X-Trace"}), Trace())
+	r.GET("/", func(c *gin.Context) { traceID = athCtx.GetTraceId(c.Request.Context()) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "bad id")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if id := w.Header().Get("X-Trace"); id == "" || id == "bad id" || id != traceID {
+		t.Errorf("X-Trace = %q, traceId = %q, want the same regenerated id", id, traceID)
+	}
+	if got := w.Header().Get(HeaderTraceID); got != "" {
+		t.Errorf("%s = %q, want empty when RequestID is registered", HeaderTraceID, got)
+	}
+}
+
+func TestTraceResponseHeader(t *testing.T) {
+	r := gin.New()
+	r.Use(TraceWithConfig(TraceConfig{ResponseHeader: "X-Trace"}))
+	r.GET("/", func(c *gin.Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("X-Trace") == "" || w.Header().Get(HeaderTraceID) != "" {
+		t.Errorf("headers = %v, want only X-Trace", w.Header())
+	}
+}
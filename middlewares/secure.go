@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+const (
+	// CSPNonceKey 当前请求CSP nonce在gin context中的key
+	CSPNonceKey = "CSPNonce"
+	// cspNoncePlaceholder ContentSecurityPolicy 中的nonce占位符
+	cspNoncePlaceholder = "{nonce}"
+)
+
+// SecureConfig 安全响应头配置，字段为空时不设置对应的响应头
+type SecureConfig struct {
+	// HSTSMaxAge Strict-Transport-Security 的 max-age
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains HSTS 是否包含子域名
+	HSTSIncludeSubdomains bool
+	// HSTSPreload HSTS 是否加入preload列表
+	HSTSPreload bool
+	// ContentTypeNosniff 设置 X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// FrameOptions X-Frame-Options，DENY 或 SAMEORIGIN
+	FrameOptions string
+	// ReferrerPolicy Referrer-Policy
+	ReferrerPolicy string
+	// PermissionsPolicy Permissions-Policy
+	PermissionsPolicy string
+	// ContentSecurityPolicy Content-Security-Policy，其中的 {nonce} 会替换为每个请求生成的nonce
+	ContentSecurityPolicy string
+	// CSPReportOnly 使用 Content-Security-Policy-Report-Only 只上报不拦截
+	CSPReportOnly bool
+}
+
+// DefaultSecureConfig 默认安全响应头，适用于只提供API的服务
+var DefaultSecureConfig = SecureConfig{
+	HSTSMaxAge:            365 * 24 * time.Hour,
+	HSTSIncludeSubdomains: true,
+	ContentTypeNosniff:    true,
+	FrameOptions:          "DENY",
+	ReferrerPolicy:        "strict-origin-when-cross-origin",
+	PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+	ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+}
+
+// Secure 使用默认配置设置安全响应头
+func Secure() gin.HandlerFunc {
+	return SecureWithConfig(DefaultSecureConfig)
+}
+
+// SecureWithConfig 设置安全响应头，可以和 Cors 一样按路由分组注册不同的配置
+func SecureWithConfig(conf SecureConfig) gin.HandlerFunc {
+	var hsts string
+	if conf.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(conf.HSTSMaxAge/time.Second))
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if conf.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	withNonce := strings.Contains(conf.ContentSecurityPolicy, cspNoncePlaceholder)
+
+	return func(c *gin.Context) {
+		if hsts != "" {
+			c.Header("Strict-Transport-Security", hsts)
+		}
+		if conf.ContentTypeNosniff {
+			c.Header("X-Content-Type-Options", "nosniff")
+		}
+		if conf.FrameOptions != "" {
+			c.Header("X-Frame-Options", conf.FrameOptions)
+		}
+		if conf.ReferrerPolicy != "" {
+			c.Header("Referrer-Policy", conf.ReferrerPolicy)
+		}
+		if conf.PermissionsPolicy != "" {
+			c.Header("Permissions-Policy", conf.PermissionsPolicy)
+		}
+		if conf.ContentSecurityPolicy != "" {
+			csp := conf.ContentSecurityPolicy
+			if withNonce {
+				nonce := newCSPNonce()
+				c.Set(CSPNonceKey, nonce)
+				csp = strings.Replace(csp, cspNoncePlaceholder, "'nonce-"+nonce+"'", -1)
+			}
+			c.Header(cspHeader, csp)
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce 获取当前请求的CSP nonce，用于模板中的 <script nonce="...">
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(CSPNonceKey)
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
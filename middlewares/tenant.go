@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"net"
+	"strings"
+)
+
+// TenantResolver 从请求中解析租户id，解析不到时返回空字符串
+type TenantResolver func(c *gin.Context) string
+
+// TenantConfig 租户配置
+type TenantConfig struct {
+	// Resolvers 按顺序解析租户，使用第一个非空的结果，默认只读取 x-auth-tenantid
+	Resolvers []TenantResolver
+	// Required 解析不到租户时返回400
+	Required bool
+}
+
+// Tenant 解析租户id，写入 CtxValue、日志字段和sentry tag，并通过 x-auth-tenantid 传递给下游
+func Tenant(conf TenantConfig) gin.HandlerFunc {
+	if len(conf.Resolvers) == 0 {
+		conf.Resolvers = []TenantResolver{HeaderTenantResolver(tracing.HeaderAuthTenantID)}
+	}
+
+	return func(c *gin.Context) {
+		var tenantID string
+		for _, resolve := range conf.Resolvers {
+			if tenantID = resolve(c); tenantID != "" {
+				break
+			}
+		}
+		if tenantID == "" {
+			if conf.Required {
+				extend.SendData(c, nil, errors.NewBadRequestError("tenant is required"))
+				c.Abort()
+				return
+			}
+			// 没有解析到租户时删除请求中的 x-auth-tenantid，不传递给下游
+			if span := tracing.SpanFromContext(c.Request.Context()); span.TenantID() != "" {
+				span.Del(tracing.HeaderAuthTenantID)
+				c.Request = c.Request.WithContext(span.ContextWithSpan(c.Request.Context()))
+			}
+			c.Next()
+			return
+		}
+
+		athValue := athCtx.GetCtxValue(c.Request.Context())
+		commonValue := athValue.GetCommonValue()
+		commonValue[athCtx.CtxValueCommonKeyTenantID] = tenantID
+		if hub := athValue.GetSentryHub(); hub != nil {
+			hub.Scope().SetTag("tenant_id", tenantID)
+		}
+		athContext, _ := athCtx.SetCtxValue(c.Request.Context(), athValue.SetCommonValue(commonValue))
+
+		span := tracing.SpanFromContext(athContext)
+		span.SetTenantID(tenantID)
+		c.Request = c.Request.WithContext(span.ContextWithSpan(athContext))
+
+		c.Next()
+	}
+}
+
+// HeaderTenantResolver 从header读取租户，如 x-auth-tenantid、x-auth-accountid
+func HeaderTenantResolver(header string) TenantResolver {
+	return func(c *gin.Context) string {
+		return strings.TrimSpace(c.GetHeader(header))
+	}
+}
+
+// SubdomainTenantResolver 使用 baseDomain 前一级的子域名作为租户，如 acme.example.com 解析为 acme
+func SubdomainTenantResolver(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.TrimPrefix(baseDomain, "."))
+	return func(c *gin.Context) string {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return ""
+		}
+		sub := strings.TrimSuffix(host, suffix)
+		if i := strings.LastIndex(sub, "."); i >= 0 {
+			sub = sub[i+1:]
+		}
+		if sub == "www" {
+			return ""
+		}
+		return sub
+	}
+}
+
+// ContextTenantResolver 读取认证中间件校验token后通过 c.Set 写入的租户，如 c.Set("tenantId", claims.TenantID)。
+// 不要直接解析请求中的token，未校验签名的claim可以被调用方任意修改
+func ContextTenantResolver(key string) TenantResolver {
+	return func(c *gin.Context) string {
+		return strings.TrimSpace(c.GetString(key))
+	}
+}
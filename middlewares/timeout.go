@@ -0,0 +1,269 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+	"github.com/hlhgogo/gin-ext/log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig 请求超时配置
+type TimeoutConfig struct {
+	// Timeout 全局超时时间，小于等于0表示不限制
+	Timeout time.Duration
+	// Routes 按路由模板(c.FullPath())单独设置的超时时间，优先于 Timeout
+	Routes map[string]time.Duration
+}
+
+// Timeout 为请求设置统一的超时时间
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: timeout})
+}
+
+// TimeoutWithConfig 为请求的context设置deadline，超时后返回504，之后handler的写入会被丢弃
+// 下游可以通过 athCtx.RemainingBudget 获取剩余的处理时间
+func TimeoutWithConfig(conf TimeoutConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := conf.Timeout
+		if d, ok := conf.Routes[c.FullPath()]; ok {
+			timeout = d
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// 超时响应直接写入原始writer，不经过handler正在使用的tw
+		path := c.Request.URL.Path
+		cp := c.Copy()
+		// 超时不上报sentry，sentry.Flush 会在持有tw的锁时阻塞handler的写入
+		cp.Set(extend.SentryCapturedKey, true)
+		tw := newTimeoutWriter(ctx, c.Writer, func(w gin.ResponseWriter) {
+			log.WarnWithTrace(ctx, "Request timeout after %s: %s", timeout, path)
+			ew := &envelopeWriter{ResponseWriter: w, status: http.StatusOK}
+			cp.Writer = ew
+			extend.SendData(cp, nil, errors.NewGatewayTimeoutError())
+			ew.flush()
+			// 持有tw的锁时写入c，之后handler的 SendData 不再记录和写入响应
+			setTimeoutResult(c, cp)
+		})
+		c.Writer = tw
+
+		done := make(chan struct{})
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			select {
+			case <-ctx.Done():
+				tw.timeout()
+			case <-done:
+			}
+		}()
+
+		defer func() {
+			close(done)
+			<-exited
+			c.Writer = tw.ResponseWriter
+		}()
+
+		c.Next()
+		if tw.finish() {
+			// handler在超时之后仍可能覆盖响应结果
+			setTimeoutResult(c, cp)
+		}
+	}
+}
+
+// setTimeoutResult 把超时响应的结果写入c，供访问日志、链路追踪和熔断使用
+func setTimeoutResult(c, cp *gin.Context) {
+	c.Set(extend.ResTimeoutKey, true)
+	c.Set(extend.ResCodeKey, cp.GetInt(extend.ResCodeKey))
+	if err, ok := cp.Get(extend.ResErrorKey); ok {
+		c.Set(extend.ResErrorKey, err)
+	}
+}
+
+// envelopeWriter 缓存超时响应，写完后带 Content-Length 写出并flush，
+// 客户端收到完整的504后不需要等待handler返回
+type envelopeWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *envelopeWriter) Status() int {
+	return w.status
+}
+
+func (w *envelopeWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *envelopeWriter) flush() {
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(w.body.Len()))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+	w.ResponseWriter.Flush()
+}
+
+// timeoutWriter 在超时前把handler的写入透传给原始writer，超时后丢弃所有写入
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	ctx       context.Context
+	onTimeout func(w gin.ResponseWriter)
+
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	wrote    bool
+	timedOut bool
+}
+
+func newTimeoutWriter(ctx context.Context, w gin.ResponseWriter, onTimeout func(w gin.ResponseWriter)) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		onTimeout:      onTimeout,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.wrote || code <= 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.commit()
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.commit() {
+		// gin在写入失败时会panic，超时后的写入直接丢弃
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.commit() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.wrote || w.timedOut {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.wrote || w.timedOut
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.commit() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// commit 把缓存的header和状态码写入原始writer，已经超时返回false，调用方需持有锁
+func (w *timeoutWriter) commit() bool {
+	if w.wrote {
+		return true
+	}
+	if w.expire() {
+		return false
+	}
+	w.wrote = true
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		if _, ok := w.header[k]; !ok {
+			dst.Del(k)
+		}
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	return true
+}
+
+// expire 超过deadline且响应还未写出时写出超时响应，调用方需持有锁
+func (w *timeoutWriter) expire() bool {
+	if w.timedOut {
+		return true
+	}
+	if w.wrote || w.ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	w.timedOut = true
+	w.onTimeout(w.ResponseWriter)
+	return true
+}
+
+// timeout ctx结束时由监听goroutine调用
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expire()
+}
+
+// finish handler执行结束后写出只设置了状态码或header的响应，已经超时返回true
+func (w *timeoutWriter) finish() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.commit()
+	return w.timedOut
+}
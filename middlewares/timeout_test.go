@@ -0,0 +1,140 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hlhgogo/gin-ext/errors"
+	"github.com/hlhgogo/gin-ext/extend"
+)
+
+func TestTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		code    int
+		resErr  interface{}
+		timeout bool
+	)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		mu.Lock()
+		defer mu.Unlock()
+		code = c.GetInt(extend.ResCodeKey)
+		resErr, _ = c.Get(extend.ResErrorKey)
+		timeout = c.GetBool(extend.ResTimeoutKey)
+	}, TimeoutWithConfig(TimeoutConfig{Timeout: time.Second, Routes: map[string]time.Duration{"/slow": 20 * time.Millisecond}}))
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(60 * time.Millisecond)
+		c.Header("X-Late", "1")
+		extend.SendData(c, "late", nil)
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		extend.SendData(c, "ok", nil)
+	})
+	r.GET("/status", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		path        string
+		wantStatus  int
+		wantCode    int
+		wantTimeout bool
+	}{
+		{"/slow", http.StatusGatewayTimeout, errors.ErrGatewayTimeout, true},
+		{"/fast", http.StatusOK, errors.Success, false},
+		{"/status", http.StatusNoContent, 0, false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+		mu.Lock()
+		if w.Code != tt.wantStatus || code != tt.wantCode || timeout != tt.wantTimeout {
+			t.Errorf("%s: status = %d, code = %d, timeout = %v, want %d, %d, %v",
+				tt.path, w.Code, code, timeout, tt.wantStatus, tt.wantCode, tt.wantTimeout)
+		}
+		if tt.wantTimeout {
+			if _, ok := resErr.(*errors.GatewayTimeoutError); !ok {
+				t.Errorf("%s: ResError = %v, want gateway timeout error", tt.path, resErr)
+			}
+			var res extend.Res
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Code != errors.ErrGatewayTimeout {
+				t.Errorf("%s: body = %s, want only the timeout response", tt.path, w.Body.String())
+			}
+			if w.Header().Get("X-Late") != "" {
+				t.Errorf("%s: late header written", tt.path)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func TestTimeoutWriterConcurrent(t *testing.T) {
+	r := gin.New()
+	r.Use(Timeout(5 * time.Millisecond))
+	r.GET("/", func(c *gin.Context) {
+		// 写入与超时同时发生，不能出现数据竞争或同时写出两个响应
+		for i := 0; i < 100; i++ {
+			c.Writer.Header().Set("X-Index", "1")
+			c.Writer.Write([]byte("x"))
+			time.Sleep(100 * time.Microsecond)
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			if w.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d once the handler has written", w.Code, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTimeoutRespondsBeforeHandlerReturns(t *testing.T) {
+	r := gin.New()
+	r.Use(Timeout(100 * time.Millisecond))
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(time.Second)
+		extend.SendData(c, "late", nil)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusGatewayTimeout)
+	}
+	var res extend.Res
+	if err := json.Unmarshal(body, &res); err != nil || res.Code != errors.ErrGatewayTimeout {
+		t.Errorf("body = %s, want the timeout response", body)
+	}
+	// 客户端在handler返回之前就收到完整的504
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("response took %s, want it shortly after the 100ms timeout", elapsed)
+	}
+}
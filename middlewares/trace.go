@@ -5,9 +5,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/hlhgogo/gin-ext/app"
 	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/extend"
 	"github.com/hlhgogo/gin-ext/log"
 	"github.com/hlhgogo/gin-ext/tracing"
-	"github.com/satori/go.uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 type responseBodyWriter struct {
@@ -20,19 +22,76 @@ func (r responseBodyWriter) Write(b []byte) (int, error) {
 	return r.ResponseWriter.Write(b)
 }
 
+func (r responseBodyWriter) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// captureBody 获取记录响应内容的buffer，Trace已经包装过writer时直接复用
+func captureBody(c *gin.Context) *bytes.Buffer {
+	if w, ok := c.Writer.(*responseBodyWriter); ok {
+		return w.body
+	}
+	w := &responseBodyWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
+	c.Writer = w
+	return w.body
+}
+
+// TraceConfig 链路追踪配置
+type TraceConfig struct {
+	// SkipPaths 不创建server span的路由，可以是路由模板如 /users/:id，也可以是请求路径如 /health
+	SkipPaths []string
+	// ResponseHeader 返回请求id的header，默认 Trace-Id。
+	// 请求id已经由 RequestID 中间件绑定时不再设置，只返回 RequestIDConfig.ResponseHeader
+	ResponseHeader string
+}
+
+// HeaderTraceID Trace 默认返回请求id的header
+const HeaderTraceID = "Trace-Id"
+
+// Trace 绑定请求id，并为每个请求创建server span
 func Trace() gin.HandlerFunc {
-	return func(c *gin.Context) {
+	return TraceWithConfig(TraceConfig{})
+}
 
-		span, _ := tracing.Extract(c.Request)
-		ctx := span.ContextWithSpan(c.Request.Context())
+// TraceWithConfig 绑定请求id，并为每个请求创建server span
+func TraceWithConfig(conf TraceConfig) gin.HandlerFunc {
+	if conf.ResponseHeader == "" {
+		conf.ResponseHeader = HeaderTraceID
+	}
+	skip := make(map[string]struct{}, len(conf.SkipPaths))
+	for _, path := range conf.SkipPaths {
+		skip[path] = struct{}{}
+	}
 
-		c.Request = c.Request.WithContext(ctx)
+	return func(c *gin.Context) {
 
-		// bind request id
-		requestId := c.Request.Header.Get("X-Request-Id")
+		// bind request id, 优先使用 RequestID 中间件已经设置的id
+		requestId := athCtx.GetTraceId(c.Request.Context())
+		bound := requestId != ""
+		if requestId == "" {
+			requestId = c.Request.Header.Get(HeaderRequestID)
+		}
 		if requestId == "" {
-			requestId = uuid.NewV4().String()
+			requestId = app.NewUUIDv4()
+		}
+
+		// span 的 x-request-id 与日志中的 traceId 保持一致，并随 Inject 传递给下游
+		span, _ := tracing.Extract(c.Request)
+		span.Set(tracing.HeaderRequestID, requestId)
+		ctx := c.Request.Context()
+
+		_, skipRoute := skip[c.FullPath()]
+		_, skipPath := skip[c.Request.URL.Path]
+		if !skipRoute && !skipPath {
+			var serverSpan opentracing.Span
+			serverSpan, span = startServerSpan(c, span)
+			defer finishServerSpan(c, serverSpan)
+			ctx = opentracing.ContextWithSpan(ctx, serverSpan)
 		}
+		ctx = span.ContextWithSpan(ctx)
+
+		c.Request = c.Request.WithContext(ctx)
 
 		// save trace id to context
 		commonValue := make(map[athCtx.CtxValueCommonKey]string)
@@ -45,7 +104,9 @@ func Trace() gin.HandlerFunc {
 		athValue = athValue.SetCommonValue(commonValue)
 		athContext, _ := athCtx.SetCtxValue(c.Request.Context(), athValue)
 		c.Request = c.Request.WithContext(athContext)
-		c.Header("Trace-Id", requestId)
+		if !bound {
+			c.Header(conf.ResponseHeader, requestId)
+		}
 
 		// ...
 		w := &responseBodyWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
@@ -57,3 +118,35 @@ func Trace() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// startServerSpan 创建server span，下游的span会成为它的子span
+func startServerSpan(c *gin.Context, span tracing.Span) (opentracing.Span, tracing.Span) {
+	operationName := c.FullPath()
+	if operationName == "" {
+		operationName = "HTTP " + c.Request.Method
+	}
+	serverSpan, span := span.StartServerSpan(operationName)
+	ext.Component.Set(serverSpan, "gin")
+	ext.HTTPMethod.Set(serverSpan, c.Request.Method)
+	ext.HTTPUrl.Set(serverSpan, c.Request.URL.Path)
+	serverSpan.SetTag("http.route", c.FullPath())
+	return serverSpan, span
+}
+
+// finishServerSpan 记录状态码和 SendData 返回的错误后结束server span
+func finishServerSpan(c *gin.Context, serverSpan opentracing.Span) {
+	status := c.Writer.Status()
+	ext.HTTPStatusCode.Set(serverSpan, uint16(status))
+	if code := c.GetInt(extend.ResCodeKey); code != 0 {
+		serverSpan.SetTag("res.code", code)
+	}
+	if v, ok := c.Get(extend.ResErrorKey); ok {
+		ext.Error.Set(serverSpan, true)
+		if err, ok := v.(error); ok {
+			serverSpan.LogKV("event", "error", "message", err.Error())
+		}
+	} else if status >= 500 {
+		ext.Error.Set(serverSpan, true)
+	}
+	serverSpan.Finish()
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/hlhgogo/config"
@@ -32,14 +33,14 @@ func Load() error {
 			DontSupportRenameColumn:   true,  //  用 `change` 重命名列，MySQL 8 之前的数据库和 MariaDB 不支持重命名列
 			SkipInitializeWithVersion: false, // 根据当前 MySQL 版本自动配置
 		}
-		if err := initClient(connName, conf, v.MaxConnNum, v.MaxIdleConn); err != nil {
+		if err := initClient(connName, conf, v.MaxConnNum, v.MaxIdleConn, traceEnabled(connName)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func initClient(client string, conf mysql.Config, maxConnNum int, maxIdleConn int) error {
+func initClient(client string, conf mysql.Config, maxConnNum int, maxIdleConn int, trace bool) error {
 	clientMapLock.Lock()
 	defer clientMapLock.Unlock()
 
@@ -56,6 +57,13 @@ func initClient(client string, conf mysql.Config, maxConnNum int, maxIdleConn in
 	// 设置数据库连接池参数
 	sqlDB.SetMaxOpenConns(maxConnNum)  // 设置数据库连接池最大连接数
 	sqlDB.SetMaxIdleConns(maxIdleConn) // 最多空闲数量
+
+	// 连接配置中 trace 为 true 时为每次操作创建子span
+	if trace {
+		if err := RegisterTracing(Conn); err != nil {
+			return err
+		}
+	}
 	ClientMap[client] = Conn
 
 	return nil
@@ -69,3 +77,12 @@ func DefaultClient() *gorm.DB {
 func Client(name string) *gorm.DB {
 	return ClientMap[name]
 }
+
+// ClientWithContext 获取绑定ctx的客户端，查询会遵循ctx的deadline
+func ClientWithContext(ctx context.Context, name string) *gorm.DB {
+	db := ClientMap[name]
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const tenantSkipKey = "tenant:skip"
+
+var (
+	// ErrTenantRequired ctx中没有租户id
+	ErrTenantRequired = errors.New("mysql: tenant is required")
+	// ErrTenantConnectionNotFound 租户没有通过 SetTenantConnections 配置连接，或者连接没有加载
+	ErrTenantConnectionNotFound = errors.New("mysql: tenant connection not found")
+	// ErrTenantScopeRawSQL 开启租户隔离后，原生sql中没有使用租户字段
+	ErrTenantScopeRawSQL = errors.New("mysql: raw sql does not reference tenant column")
+)
+
+var (
+	// tenantConnections 租户id到 ClientMap 连接名的映射
+	tenantConnections     = map[string]string{}
+	tenantConnectionsLock sync.RWMutex
+)
+
+// TenantID 获取 Tenant 中间件解析的租户id，不使用请求中未经校验的 x-auth-tenantid
+func TenantID(ctx context.Context) string {
+	return athCtx.GetTenantID(ctx)
+}
+
+// SetTenantConnections 设置租户id到连接名的映射，如 {"acme": "tenant_acme"}，会替换之前的配置
+func SetTenantConnections(connections map[string]string) {
+	m := make(map[string]string, len(connections))
+	for tenantID, connName := range connections {
+		m[tenantID] = connName
+	}
+	tenantConnectionsLock.Lock()
+	defer tenantConnectionsLock.Unlock()
+	tenantConnections = m
+}
+
+// TenantClient 获取租户的客户端，只使用 SetTenantConnections 中配置的连接，
+// 没有配置的租户返回 ErrTenantConnectionNotFound，不会使用default或与租户id同名的连接
+func TenantClient(ctx context.Context) (*gorm.DB, error) {
+	tenantID := TenantID(ctx)
+	if tenantID == "" {
+		return nil, ErrTenantRequired
+	}
+
+	tenantConnectionsLock.RLock()
+	connName, ok := tenantConnections[tenantID]
+	tenantConnectionsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: tenant %s", ErrTenantConnectionNotFound, tenantID)
+	}
+	db := ClientMap[connName]
+	if db == nil {
+		return nil, fmt.Errorf("%w: tenant %s, connection %s", ErrTenantConnectionNotFound, tenantID, connName)
+	}
+	return db.WithContext(ctx), nil
+}
+
+// SkipTenantScope 跳过 EnableTenantScope 的租户条件，用于跨租户的管理操作
+func SkipTenantScope(db *gorm.DB) *gorm.DB {
+	return db.Set(tenantSkipKey, true)
+}
+
+// EnableTenantScope 注册回调，model 包含 column 字段时，查询、更新、删除自动追加租户条件，创建时写入租户id；
+// Raw、Exec 等原生sql中没有出现 column 时返回 ErrTenantScopeRawSQL，需要使用 WithContext 传入带租户的ctx
+func EnableTenantScope(db *gorm.DB, column string) error {
+	columnPattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(column) + `\b`)
+
+	checkRaw := func(db *gorm.DB) {
+		if !columnPattern.MatchString(db.Statement.SQL.String()) {
+			db.AddError(fmt.Errorf("%w: %s", ErrTenantScopeRawSQL, column))
+		}
+	}
+	scope := func(db *gorm.DB) {
+		tenantID := tenantScopeID(db)
+		if tenantID == "" {
+			return
+		}
+		if db.Statement.SQL.Len() > 0 {
+			checkRaw(db)
+			return
+		}
+		if db.Statement.Schema == nil || db.Statement.Schema.LookUpField(column) == nil {
+			return
+		}
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: tenantID},
+		}})
+	}
+	create := func(db *gorm.DB) {
+		tenantID := tenantScopeID(db)
+		if tenantID == "" {
+			return
+		}
+		if db.Statement.SQL.Len() > 0 {
+			checkRaw(db)
+			return
+		}
+		if db.Statement.Schema != nil && db.Statement.Schema.LookUpField(column) == nil {
+			return
+		}
+		db.Statement.SetColumn(column, tenantID, true)
+	}
+	raw := func(db *gorm.DB) {
+		if tenantScopeID(db) != "" {
+			checkRaw(db)
+		}
+	}
+
+	callback := db.Callback()
+	if err := callback.Create().Before("gorm:create").Register("tenant:create", create); err != nil {
+		return err
+	}
+	if err := callback.Query().Before("gorm:query").Register("tenant:query", scope); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("tenant:update", scope); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("tenant:delete", scope); err != nil {
+		return err
+	}
+	if err := callback.Row().Before("gorm:row").Register("tenant:row", scope); err != nil {
+		return err
+	}
+	return callback.Raw().Before("gorm:raw").Register("tenant:raw", raw)
+}
+
+// tenantScopeID 需要追加租户条件时返回租户id
+func tenantScopeID(db *gorm.DB) string {
+	if db.Statement.Context == nil {
+		return ""
+	}
+	if skip, ok := db.Get(tenantSkipKey); ok && skip == true {
+		return ""
+	}
+	return TenantID(db.Statement.Context)
+}
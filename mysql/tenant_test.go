@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/tracing"
+)
+
+type tenantOrder struct {
+	ID       uint
+	TenantID string
+	Amount   int
+}
+
+func tenantContext(tenantID string) context.Context {
+	ctx, _ := athCtx.SetCtxValue(context.Background(), athCtx.NewCtxValue(map[athCtx.CtxValueCommonKey]string{
+		athCtx.CtxValueCommonKeyTenantID: tenantID,
+	}))
+	return ctx
+}
+
+func TestTenantClient(t *testing.T) {
+	db := newDryRunDB(t)
+	ClientMap[DefaultCli] = db
+	ClientMap["tenant_acme"] = db
+	defer func() {
+		delete(ClientMap, DefaultCli)
+		delete(ClientMap, "tenant_acme")
+		SetTenantConnections(nil)
+	}()
+	SetTenantConnections(map[string]string{"acme": "tenant_acme", "ghost": "tenant_ghost"})
+
+	if client, err := TenantClient(tenantContext("acme")); err != nil || client == nil {
+		t.Errorf("TenantClient(acme) = %v, %v, want client", client, err)
+	}
+	if _, err := TenantClient(context.Background()); err != ErrTenantRequired {
+		t.Errorf("TenantClient() error = %v, want %v", err, ErrTenantRequired)
+	}
+	// 请求中未经校验的 x-auth-tenantid 不能选择租户连接
+	headerOnly := tracing.Span{tracing.HeaderAuthTenantID: "acme"}.ContextWithSpan(context.Background())
+	if _, err := TenantClient(headerOnly); err != ErrTenantRequired {
+		t.Errorf("TenantClient(header only) error = %v, want %v", err, ErrTenantRequired)
+	}
+	for _, tenantID := range []string{"default", "tenant_acme", "other", "ghost"} {
+		if _, err := TenantClient(tenantContext(tenantID)); !errors.Is(err, ErrTenantConnectionNotFound) {
+			t.Errorf("TenantClient(%s) error = %v, want %v", tenantID, err, ErrTenantConnectionNotFound)
+		}
+	}
+}
+
+func TestEnableTenantScope(t *testing.T) {
+	db := newDryRunDB(t)
+	if err := EnableTenantScope(db, "tenant_id"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tenantContext("acme")
+
+	stmt := db.WithContext(ctx).Where("amount > ?", 1).Find(&[]tenantOrder{}).Statement
+	if got, want := stmt.SQL.String(), "SELECT * FROM `tenant_orders` WHERE amount > ? AND `tenant_orders`.`tenant_id` = ?"; got != want {
+		t.Errorf("query sql = %q, want %q", got, want)
+	}
+
+	stmt = db.WithContext(ctx).Model(&tenantOrder{}).Where("id = ?", 1).Update("amount", 2).Statement
+	if got, want := stmt.SQL.String(), "UPDATE `tenant_orders` SET `amount`=? WHERE id = ? AND `tenant_orders`.`tenant_id` = ?"; got != want {
+		t.Errorf("update sql = %q, want %q", got, want)
+	}
+
+	stmt = db.WithContext(ctx).Where("id = ?", 1).Delete(&tenantOrder{}).Statement
+	if got, want := stmt.SQL.String(), "DELETE FROM `tenant_orders` WHERE id = ? AND `tenant_orders`.`tenant_id` = ?"; got != want {
+		t.Errorf("delete sql = %q, want %q", got, want)
+	}
+
+	order := tenantOrder{TenantID: "other", Amount: 1}
+	db.WithContext(ctx).Create(&order)
+	if order.TenantID != "acme" {
+		t.Errorf("created tenant = %q, want acme", order.TenantID)
+	}
+
+	if err := db.WithContext(ctx).Exec("DELETE FROM tenant_orders WHERE id = ?", 1).Error; !errors.Is(err, ErrTenantScopeRawSQL) {
+		t.Errorf("raw exec error = %v, want %v", err, ErrTenantScopeRawSQL)
+	}
+	if err := db.WithContext(ctx).Raw("SELECT * FROM tenant_orders").Scan(&[]tenantOrder{}).Error; !errors.Is(err, ErrTenantScopeRawSQL) {
+		t.Errorf("raw query error = %v, want %v", err, ErrTenantScopeRawSQL)
+	}
+	if err := db.WithContext(ctx).Exec("DELETE FROM tenant_orders WHERE tenant_id = ? AND id = ?", "acme", 1).Error; err != nil {
+		t.Errorf("raw exec with tenant column error = %v", err)
+	}
+	if err := SkipTenantScope(db.WithContext(ctx)).Exec("DELETE FROM tenant_orders WHERE id = ?", 1).Error; err != nil {
+		t.Errorf("skipped raw exec error = %v", err)
+	}
+
+	stmt = db.WithContext(context.Background()).Find(&[]tenantOrder{}).Statement
+	if got, want := stmt.SQL.String(), "SELECT * FROM `tenant_orders`"; got != want {
+		t.Errorf("query without tenant sql = %q, want %q", got, want)
+	}
+}
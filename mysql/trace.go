@@ -0,0 +1,141 @@
+package mysql
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/hlhgogo/config"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"gorm.io/gorm"
+)
+
+const (
+	// traceConfigKey mysql连接配置中开启链路追踪的key，如 {"mysql": {"default": {"trace": true}}}
+	traceConfigKey = "trace"
+
+	spanInstanceKey = "tracing:span"
+
+	// maxStatementLength 写入span的sql最大长度
+	maxStatementLength = 1024
+)
+
+// sqlLiteral sql中的反引号标识符、字符串常量和数字常量，标识符原样保留
+var sqlLiteral = regexp.MustCompile("`[^`]*`" + `|'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|\b0[xX][0-9a-fA-F]+\b|\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`)
+
+// traceEnabled 读取连接配置中的 trace
+func traceEnabled(connName string) bool {
+	conf := config.Get()
+	if conf == nil {
+		return false
+	}
+	m, ok := conf.MySql[connName].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	switch v := m[traceConfigKey].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	default:
+		return false
+	}
+}
+
+// RegisterTracing 注册回调，为 create、query、update、delete、row、raw 创建子span，
+// 需要使用 WithContext 传入带链路信息的ctx，ctx没有链路信息时不创建span
+func RegisterTracing(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Create().Before("gorm:create").Register("tracing:before_create", startSpan("gorm:create")); err != nil {
+		return err
+	}
+	if err := callback.Create().After("gorm:create").Register("tracing:after_create", finishSpan); err != nil {
+		return err
+	}
+	if err := callback.Query().Before("gorm:query").Register("tracing:before_query", startSpan("gorm:query")); err != nil {
+		return err
+	}
+	if err := callback.Query().After("gorm:query").Register("tracing:after_query", finishSpan); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("tracing:before_update", startSpan("gorm:update")); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:update").Register("tracing:after_update", finishSpan); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("gorm:delete")); err != nil {
+		return err
+	}
+	if err := callback.Delete().After("gorm:delete").Register("tracing:after_delete", finishSpan); err != nil {
+		return err
+	}
+	if err := callback.Row().Before("gorm:row").Register("tracing:before_row", startSpan("gorm:row")); err != nil {
+		return err
+	}
+	if err := callback.Row().After("gorm:row").Register("tracing:after_row", finishSpan); err != nil {
+		return err
+	}
+	if err := callback.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("gorm:raw")); err != nil {
+		return err
+	}
+	return callback.Raw().After("gorm:raw").Register("tracing:after_raw", finishSpan)
+}
+
+// startSpan 创建子span并保存到 gorm 实例中
+func startSpan(operationName string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		span := tracing.SpanFromContext(db.Statement.Context)
+		if span.Empty() {
+			return
+		}
+		dbSpan := span.StartChildSpan(operationName, ext.SpanKindRPCClient)
+		ext.Component.Set(dbSpan, "gorm")
+		ext.DBType.Set(dbSpan, "mysql")
+		db.InstanceSet(spanInstanceKey, dbSpan)
+	}
+}
+
+// finishSpan 记录表名、sql、影响行数和错误后结束span
+func finishSpan(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	dbSpan, ok := v.(opentracing.Span)
+	if !ok {
+		return
+	}
+
+	if db.Statement.Table != "" {
+		dbSpan.SetTag("db.table", db.Statement.Table)
+	}
+	ext.DBStatement.Set(dbSpan, sanitizeSQL(db.Statement.SQL.String()))
+	dbSpan.SetTag("db.rows_affected", db.Statement.RowsAffected)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		ext.Error.Set(dbSpan, true)
+		dbSpan.LogKV("event", "error", "message", db.Error.Error())
+	}
+	dbSpan.Finish()
+}
+
+// sanitizeSQL 把 Raw、Exec 中直接写入的字符串和数字常量替换为占位符，压缩空白并限制长度
+func sanitizeSQL(sql string) string {
+	sql = sqlLiteral.ReplaceAllStringFunc(sql, func(literal string) string {
+		if strings.HasPrefix(literal, "`") {
+			return literal
+		}
+		return "?"
+	})
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxStatementLength {
+		sql = sql[:maxStatementLength] + "..."
+	}
+	return sql
+}
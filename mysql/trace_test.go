@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM `orders` WHERE id > ?", "SELECT * FROM `orders` WHERE id > ?"},
+		{"SELECT *\n  FROM users WHERE name = 'bob' AND note = 'it''s'", "SELECT * FROM users WHERE name = ? AND note = ?"},
+		{`UPDATE users SET token = "abc\"def" WHERE id = 1`, "UPDATE users SET token = ? WHERE id = ?"},
+		{"SELECT * FROM `orders_2021` o2 WHERE o2.amount >= 12.5 AND o2.card = 4111111111111111 LIMIT 10", "SELECT * FROM `orders_2021` o2 WHERE o2.amount >= ? AND o2.card = ? LIMIT ?"},
+		{"SELECT * FROM t WHERE flags = 0xFF OR score < -1.5e3", "SELECT * FROM t WHERE flags = ? OR score < -?"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeSQL(tt.sql); got != tt.want {
+			t.Errorf("sanitizeSQL(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterTracing(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	shutdown, err := tracing.Init(tracing.Options{ServiceName: "mysql-test", Reporter: reporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	db := newDryRunDB(t)
+	if err := RegisterTracing(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tracing.Span{
+		"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":  "b7ad6b7169203331",
+		"x-b3-sampled": "1",
+	}.ContextWithSpan(context.Background())
+
+	db.WithContext(ctx).Where("amount > 100 AND note = 'secret'").Find(&[]tenantOrder{})
+	db.WithContext(ctx).Exec("UPDATE tenant_orders SET note = 'secret' WHERE id = 42")
+	// 没有链路信息时不创建span
+	db.WithContext(context.Background()).Find(&[]tenantOrder{})
+
+	spans := reporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	want := map[string]string{
+		"gorm:query": "SELECT * FROM `tenant_orders` WHERE amount > ? AND note = ?",
+		"gorm:raw":   "UPDATE tenant_orders SET note = ? WHERE id = ?",
+	}
+	for _, s := range spans {
+		span := s.(*jaeger.Span)
+		statement, _ := span.Tags()[string(ext.DBStatement)].(string)
+		if statement != want[span.OperationName()] {
+			t.Errorf("%s statement = %q, want %q", span.OperationName(), statement, want[span.OperationName()])
+		}
+		if strings.Contains(statement, "secret") || strings.Contains(statement, "42") {
+			t.Errorf("%s statement leaks literals: %q", span.OperationName(), statement)
+		}
+		if got := span.Context().(jaeger.SpanContext).TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("%s trace id = %s", span.OperationName(), got)
+		}
+	}
+}
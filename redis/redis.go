@@ -30,7 +30,8 @@ func Load() error {
 			PoolSize:     v.PoolSize,
 			PoolTimeout:  v.PoolTimeout * time.Second,
 		}
-		if err := initClient(connName, conf); err != nil {
+		trace, slowThreshold, keyPrefixes := hookOptions(connName)
+		if err := initClient(connName, conf, trace, slowThreshold, keyPrefixes); err != nil {
 			return err
 		}
 	}
@@ -38,7 +39,7 @@ func Load() error {
 }
 
 // initClient 初始化客户端
-func initClient(client string, rOpt *redis.Options) error {
+func initClient(client string, rOpt *redis.Options, trace bool, slowThreshold time.Duration, keyPrefixes []string) error {
 	clientMapLock.Lock()
 	defer clientMapLock.Unlock()
 
@@ -47,6 +48,12 @@ func initClient(client string, rOpt *redis.Options) error {
 		return err
 	}
 
+	// 连接配置中开启 trace 或设置 slow_threshold 时注册hook
+	if trace || slowThreshold > 0 {
+		hook := NewTraceHook(client, trace, slowThreshold)
+		hook.KeyPrefixes = keyPrefixes
+		conn.AddHook(hook)
+	}
 	clientMap[client] = conn
 
 	return nil
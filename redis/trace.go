@@ -0,0 +1,241 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hlhgogo/config"
+	"github.com/hlhgogo/gin-ext/log"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// traceConfigKey redis连接配置中开启链路追踪的key，如 {"redis": {"default": {"trace": true}}}
+	traceConfigKey = "trace"
+	// slowThresholdConfigKey redis连接配置中慢命令阈值的key，单位毫秒，如 {"redis": {"default": {"slow_threshold": 100}}}
+	slowThresholdConfigKey = "slow_threshold"
+	// keyPrefixesConfigKey redis连接配置中key静态前缀的key，如 {"redis": {"default": {"key_prefixes": ["user:*:profile", "config"]}}}
+	keyPrefixesConfigKey = "key_prefixes"
+)
+
+// noKeyCommands 参数中不包含key的命令
+var noKeyCommands = map[string]struct{}{
+	"ping": {}, "echo": {}, "info": {}, "auth": {}, "select": {}, "hello": {}, "client": {}, "config": {},
+	"script": {}, "quit": {}, "flushdb": {}, "flushall": {}, "dbsize": {}, "time": {}, "multi": {}, "exec": {},
+	"discard": {}, "unwatch": {}, "scan": {}, "keys": {}, "randomkey": {}, "command": {}, "slowlog": {},
+}
+
+type hookContextKey struct{}
+
+// hookState BeforeProcess 保存到ctx中，AfterProcess 读取
+type hookState struct {
+	span  opentracing.Span
+	start time.Time
+}
+
+// TraceHook 为命令和pipeline创建子span，记录慢命令，span和日志中只包含命令名和key的模式，不包含值
+type TraceHook struct {
+	// Name 客户端名称
+	Name string
+	// Trace 创建子span，ctx没有链路信息时不创建
+	Trace bool
+	// SlowThreshold 超过该耗时的命令记录warning日志，0表示不记录
+	SlowThreshold time.Duration
+	// KeyPrefixes key的静态前缀，用:分隔，*匹配任意片段，如 user:*:profile。
+	// span和日志中只保留匹配到的静态片段，其他片段都替换为*
+	KeyPrefixes []string
+}
+
+// NewTraceHook 创建hook
+func NewTraceHook(name string, trace bool, slowThreshold time.Duration) *TraceHook {
+	return &TraceHook{Name: name, Trace: trace, SlowThreshold: slowThreshold}
+}
+
+// BeforeProcess implements redis.Hook
+func (h *TraceHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.before(ctx, "redis:"+cmd.Name(), commandStatement(cmd, h.KeyPrefixes)), nil
+}
+
+// AfterProcess implements redis.Hook
+func (h *TraceHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.after(ctx, commandStatement(cmd, h.KeyPrefixes), cmd.Err())
+	return nil
+}
+
+// BeforeProcessPipeline implements redis.Hook
+func (h *TraceHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx = h.before(ctx, "redis:pipeline", pipelineStatement(cmds, h.KeyPrefixes))
+	if state, ok := ctx.Value(hookContextKey{}).(*hookState); ok && state.span != nil {
+		state.span.SetTag("redis.pipeline_length", len(cmds))
+	}
+	return ctx, nil
+}
+
+// AfterProcessPipeline implements redis.Hook
+func (h *TraceHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && cmdErr != redis.Nil {
+			err = cmdErr
+			break
+		}
+	}
+	h.after(ctx, pipelineStatement(cmds, h.KeyPrefixes), err)
+	return nil
+}
+
+func (h *TraceHook) before(ctx context.Context, operationName, statement string) context.Context {
+	state := &hookState{start: time.Now()}
+	if h.Trace {
+		if span := tracing.SpanFromContext(ctx); !span.Empty() {
+			state.span = span.StartChildSpan(operationName, ext.SpanKindRPCClient)
+			ext.Component.Set(state.span, "go-redis")
+			ext.DBType.Set(state.span, "redis")
+			ext.DBInstance.Set(state.span, h.Name)
+			ext.DBStatement.Set(state.span, statement)
+		}
+	}
+	return context.WithValue(ctx, hookContextKey{}, state)
+}
+
+func (h *TraceHook) after(ctx context.Context, statement string, err error) {
+	state, ok := ctx.Value(hookContextKey{}).(*hookState)
+	if !ok {
+		return
+	}
+	if err == redis.Nil {
+		err = nil
+	}
+
+	elapsed := time.Since(state.start)
+	if h.SlowThreshold > 0 && elapsed >= h.SlowThreshold {
+		log.WarnFieldsWithTrace(ctx, logrus.Fields{
+			"client":     h.Name,
+			"statement":  statement,
+			"latency_ms": float64(elapsed.Microseconds()) / 1000,
+		}, "Redis slow command")
+	}
+
+	if state.span == nil {
+		return
+	}
+	if err != nil {
+		ext.Error.Set(state.span, true)
+		state.span.LogKV("event", "error", "message", err.Error())
+	}
+	state.span.Finish()
+}
+
+// commandStatement 命令名和key的模式，如 get user:*:profile
+func commandStatement(cmd redis.Cmder, prefixes []string) string {
+	name := strings.ToLower(cmd.Name())
+	args := cmd.Args()
+	keyIndex := 1
+	if name == "eval" || name == "evalsha" {
+		// eval script numkeys key [key ...]
+		keyIndex = 3
+		if len(args) > 2 && toString(args[2]) == "0" {
+			return name
+		}
+	}
+	if _, ok := noKeyCommands[name]; ok || len(args) <= keyIndex {
+		return name
+	}
+	return name + " " + keyPattern(toString(args[keyIndex]), prefixes)
+}
+
+// pipelineStatement pipeline中的命令，如 get user:*;set user:*
+func pipelineStatement(cmds []redis.Cmder, prefixes []string) string {
+	statements := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		statements = append(statements, commandStatement(cmd, prefixes))
+	}
+	return strings.Join(statements, ";")
+}
+
+// keyPattern 只保留key中匹配 prefixes 的静态片段，其他片段都替换为*，
+// 如 prefixes 为 user:*:profile 时 user:alice:profile:v2 转换为 user:*:profile:*
+func keyPattern(key string, prefixes []string) string {
+	segments := strings.Split(key, ":")
+	var known []string
+	for _, prefix := range prefixes {
+		parts := strings.Split(strings.TrimSuffix(prefix, ":"), ":")
+		if len(parts) > len(known) && matchKeyPrefix(segments, parts) {
+			known = parts
+		}
+	}
+	for i := range segments {
+		if i >= len(known) || known[i] == "*" {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, ":")
+}
+
+// matchKeyPrefix 判断key的片段是否以静态前缀开头
+func matchKeyPrefix(segments, prefix []string) bool {
+	if len(prefix) > len(segments) {
+		return false
+	}
+	for i, part := range prefix {
+		if part != "*" && part != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return ""
+	}
+}
+
+// hookOptions 读取连接配置中的 trace、slow_threshold 和 key_prefixes
+func hookOptions(connName string) (trace bool, slowThreshold time.Duration, keyPrefixes []string) {
+	conf := config.Get()
+	if conf == nil {
+		return false, 0, nil
+	}
+	m, ok := conf.Redis[connName].(map[string]interface{})
+	if !ok {
+		return false, 0, nil
+	}
+	switch v := m[traceConfigKey].(type) {
+	case bool:
+		trace = v
+	case string:
+		trace = v == "true" || v == "1"
+	}
+	switch v := m[slowThresholdConfigKey].(type) {
+	case float64:
+		slowThreshold = time.Duration(v) * time.Millisecond
+	case string:
+		if ms, err := strconv.Atoi(v); err == nil {
+			slowThreshold = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v, ok := m[keyPrefixesConfigKey].([]interface{}); ok {
+		for _, prefix := range v {
+			if prefix, ok := prefix.(string); ok && prefix != "" {
+				keyPrefixes = append(keyPrefixes, prefix)
+			}
+		}
+	}
+	return trace, slowThreshold, keyPrefixes
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCommandStatement(t *testing.T) {
+	ctx := context.Background()
+	prefixes := []string{"user:*:profile", "session", "lock"}
+	tests := []struct {
+		cmd  redis.Cmder
+		want string
+	}{
+		{redis.NewStringCmd(ctx, "get", "user:123:profile"), "get user:*:profile"},
+		{redis.NewStatusCmd(ctx, "set", "session:abc", "secret-value"), "set session:*"},
+		{redis.NewCmd(ctx, "eval", "return 1", 1, "lock:9", "secret"), "eval lock:*"},
+		{redis.NewCmd(ctx, "eval", "return 1", 0), "eval"},
+		{redis.NewStatusCmd(ctx, "ping"), "ping"},
+	}
+	for _, tt := range tests {
+		if got := commandStatement(tt.cmd, prefixes); got != tt.want {
+			t.Errorf("commandStatement(%v) = %q, want %q", tt.cmd.Args(), got, tt.want)
+		}
+	}
+}
+
+func TestKeyPattern(t *testing.T) {
+	prefixes := []string{"user", "user:*:profile", "config:global", "cache:"}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"user:alice:profile", "user:*:profile"},
+		{"user:alice@example.com", "user:*"},
+		{"user:123:orders", "user:*:*"},
+		{"user:alice:profile:v2", "user:*:profile:*"},
+		{"config:global", "config:global"},
+		{"config:tenant", "*:*"},
+		{"cache:GET:/api/orders", "cache:*:*"},
+		{"alice@example.com", "*"},
+		{"orders:bob", "*:*"},
+	}
+	for _, tt := range tests {
+		if got := keyPattern(tt.key, prefixes); got != tt.want {
+			t.Errorf("keyPattern(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if got := keyPattern("user:alice:profile", nil); got != "*:*:*" {
+		t.Errorf("keyPattern without prefixes = %q, want *:*:*", got)
+	}
+}
@@ -26,7 +26,21 @@ package errgroup
 //			// do something
 //		})
 //
-//设置最大并行数 GOMAXPROCS 对以上三种使用方式均起效
+//4、WithTrace、WithTraceCancel 与 WithContext、WithCancel 相同，每个任务的 ctx 包含 CtxValue 的副本和子span:
+//		g := errgroup.WithTrace(ctx)
+//		g.GoNamed("load-user", func(ctx context.Context) error {
+//			// NOTE: 此时 ctx 中的子span名称为 load-user, panic 转换的 err 中包含 load-user
+//			// do something
+//		})
+//
+//5、GoCtx 任意 Group（包括 &errgroup.Group{}）都可以使用，任务的链路信息来自传入的 ctx:
+//		g := &errgroup.Group{}
+//		g.GoCtx(ctx, "load-user", func(ctx context.Context) error {
+//			// NOTE: 此时 ctx 包含传入 ctx 的 CtxValue 副本和名称为 load-user 的子span
+//			// do something
+//		})
+//
+//设置最大并行数 GOMAXPROCS 对以上几种使用方式均起效
 //NOTE: 由于 errgroup 实现问题,设定 GOMAXPROCS 的 errgroup 需要立即调用 Wait() 例如:
 //
 //		g := errgroup.WithCancel(ctx)
@@ -23,6 +23,7 @@ type Group struct {
 
 	ctx    context.Context
 	cancel func()
+	trace  bool
 }
 
 // WithContext create a Group.
@@ -89,6 +90,13 @@ func (g *Group) GOMAXPROCS(n int) {
 // The first call to return a non-nil error cancels the group; its error will be
 // returned by Wait.
 func (g *Group) Go(f func(ctx context.Context) error) {
+	if g.trace {
+		f = traceTask(defaultTaskName, f)
+	}
+	g.add(f)
+}
+
+func (g *Group) add(f func(ctx context.Context) error) {
 	g.wg.Add(1)
 	if g.ch != nil {
 		select {
@@ -0,0 +1,92 @@
+package errgroup
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// defaultTaskName WithTrace、WithTraceCancel 创建的 Group 调用 Go 时的任务名称
+const defaultTaskName = "errgroup.task"
+
+// WithTrace 与 WithContext 相同，每个任务使用 ctx 中 CtxValue 的副本，并在 ctx 有链路信息时创建子span
+func WithTrace(ctx context.Context) *Group {
+	return &Group{ctx: ctx, trace: true}
+}
+
+// WithTraceCancel 与 WithCancel 相同，每个任务使用 ctx 中 CtxValue 的副本，并在 ctx 有链路信息时创建子span
+func WithTraceCancel(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel, trace: true}
+}
+
+// GoNamed 与 Go 相同，子span使用 name 作为名称，panic 转换的 err 中包含 name
+//
+// NOTE: 直接使用 &errgroup.Group{} 时任务的 ctx 为 context.Background()，不包含链路信息，需要链路信息时使用 GoCtx
+func (g *Group) GoNamed(name string, f func(ctx context.Context) error) {
+	g.add(traceTask(name, f))
+}
+
+// GoCtx 与 GoNamed 相同，任务的链路信息和 CtxValue 来自 ctx，取消仍然由 Group 的 ctx 控制，
+// 直接使用 &errgroup.Group{} 或 WithContext 创建的 Group 也可以保留链路信息
+func (g *Group) GoCtx(ctx context.Context, name string, f func(ctx context.Context) error) {
+	task := traceTask(name, f)
+	g.add(func(groupCtx context.Context) error {
+		return task(withTraceFrom(groupCtx, ctx))
+	})
+}
+
+// withTraceFrom 将 src 中的 CtxValue 和链路信息复制到 dst
+func withTraceFrom(dst, src context.Context) context.Context {
+	if src == nil {
+		return dst
+	}
+	if cv, ok := src.Value(athCtx.CtxValueKeyV1).(*athCtx.CtxValue); ok {
+		dst, _ = athCtx.SetCtxValue(dst, cv)
+	}
+	if span := opentracing.SpanFromContext(src); span != nil {
+		dst = opentracing.ContextWithSpan(dst, span)
+	}
+	if span := tracing.SpanFromContext(src); !span.Empty() {
+		dst = span.ContextWithSpan(dst)
+	}
+	return dst
+}
+
+// traceTask 为任务复制 CtxValue、创建子span，recover panic 并记录到span
+func traceTask(name string, f func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) (err error) {
+		if cv, ok := ctx.Value(athCtx.CtxValueKeyV1).(*athCtx.CtxValue); ok {
+			ctx, _ = athCtx.SetCtxValue(ctx, cv.Clone())
+		}
+
+		var taskSpan opentracing.Span
+		if span := tracing.SpanFromContext(ctx); !span.Empty() {
+			taskSpan = span.StartChildSpan(name)
+			ctx = opentracing.ContextWithSpan(ctx, taskSpan)
+			ctx = span.WithOpentracing(taskSpan).ContextWithSpan(ctx)
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				buf := make([]byte, 64<<10)
+				buf = buf[:runtime.Stack(buf, false)]
+				err = fmt.Errorf("errgroup: task %s panic recovered: %s\n%s", name, r, buf)
+			}
+			if taskSpan == nil {
+				return
+			}
+			if err != nil {
+				ext.Error.Set(taskSpan, true)
+				taskSpan.LogKV("event", "error", "message", err.Error())
+			}
+			taskSpan.Finish()
+		}()
+		return f(ctx)
+	}
+}
@@ -0,0 +1,103 @@
+package errgroup
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/hlhgogo/gin-ext/tracing"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestWithTrace(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	shutdown, err := tracing.Init(tracing.Options{ServiceName: "errgroup-test", Reporter: reporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	ctx, _ := athCtx.SetCtxValue(context.Background(), athCtx.NewCtxValue(map[athCtx.CtxValueCommonKey]string{
+		athCtx.CtxValueCommonKeyTraceID: "trace-1",
+	}))
+	ctx = tracing.Span{
+		"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":  "b7ad6b7169203331",
+		"x-b3-sampled": "1",
+	}.ContextWithSpan(ctx)
+
+	g := WithTrace(ctx)
+	g.GoNamed("load-user", func(ctx context.Context) error {
+		if traceID := athCtx.GetTraceId(ctx); traceID != "trace-1" {
+			t.Errorf("traceId = %q, want trace-1", traceID)
+		}
+		if span := tracing.SpanFromContext(ctx); span.SpanID() == "b7ad6b7169203331" {
+			t.Error("task span id should be the child span id")
+		}
+		return nil
+	})
+	g.GoNamed("load-orders", func(ctx context.Context) error {
+		panic("boom")
+	})
+	err = g.Wait()
+	if err == nil || !strings.Contains(err.Error(), "task load-orders panic recovered: boom") {
+		t.Errorf("Wait() error = %v, want panic error with task name", err)
+	}
+
+	names := map[string]bool{}
+	for _, s := range reporter.GetSpans() {
+		span := s.(*jaeger.Span)
+		names[span.OperationName()] = true
+		if got := span.Context().(jaeger.SpanContext).TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+			t.Errorf("%s trace id = %s", span.OperationName(), got)
+		}
+	}
+	if !names["load-user"] || !names["load-orders"] {
+		t.Errorf("spans = %v, want load-user and load-orders", names)
+	}
+}
+
+func TestGoCtx(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	shutdown, err := tracing.Init(tracing.Options{ServiceName: "errgroup-test", Reporter: reporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	ctx, _ := athCtx.SetCtxValue(context.Background(), athCtx.NewCtxValue(map[athCtx.CtxValueCommonKey]string{
+		athCtx.CtxValueCommonKeyTraceID: "trace-1",
+	}))
+	ctx = tracing.Span{
+		"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":  "b7ad6b7169203331",
+		"x-b3-sampled": "1",
+	}.ContextWithSpan(ctx)
+
+	g := &Group{}
+	g.GoCtx(ctx, "load-user", func(ctx context.Context) error {
+		if traceID := athCtx.GetTraceId(ctx); traceID != "trace-1" {
+			t.Errorf("traceId = %q, want trace-1", traceID)
+		}
+		if span := tracing.SpanFromContext(ctx); span.Empty() || span.SpanID() == "b7ad6b7169203331" {
+			t.Error("task span should be a child of the ctx span")
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := reporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("spans = %d, want 1", len(spans))
+	}
+	span := spans[0].(*jaeger.Span)
+	if span.OperationName() != "load-user" {
+		t.Errorf("operation = %s, want load-user", span.OperationName())
+	}
+	if got := span.Context().(jaeger.SpanContext).TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("trace id = %s", got)
+	}
+}
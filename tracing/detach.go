@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	athLog "github.com/hlhgogo/gin-ext/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/sirupsen/logrus"
+)
+
+// GoDetached 在新的goroutine中执行f，f的ctx由 context.Detach 创建，不会随请求取消，
+// ctx有链路信息时创建与请求span是 follows-from 关系的span，panic 记录日志和sentry后不会导致进程退出
+func GoDetached(ctx context.Context, operationName string, f func(ctx context.Context)) {
+	ctx = athCtx.Detach(ctx)
+	go func() {
+		var detachedSpan opentracing.Span
+		if span := SpanFromContext(ctx); !span.Empty() {
+			detachedSpan = span.StartFollowsFromSpan(operationName)
+			ctx = opentracing.ContextWithSpan(ctx, detachedSpan)
+			ctx = span.WithOpentracing(detachedSpan).ContextWithSpan(ctx)
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", r)
+				}
+				athLog.ErrorFieldsWithTrace(ctx, logrus.Fields{"operation": operationName, "panic_stack": string(debug.Stack())}, err, "Detached goroutine panic")
+				if detachedSpan != nil {
+					ext.Error.Set(detachedSpan, true)
+					detachedSpan.LogKV("event", "error", "message", err.Error())
+				}
+			}
+			if detachedSpan != nil {
+				detachedSpan.Finish()
+			}
+		}()
+		f(ctx)
+	}()
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+func TestGoDetached(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	shutdown, err := Init(Options{ServiceName: "detach-test", Reporter: reporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, _ = athCtx.SetCtxValue(ctx, athCtx.NewCtxValue(map[athCtx.CtxValueCommonKey]string{
+		athCtx.CtxValueCommonKeyTraceID: "trace-1",
+	}))
+	ctx = Span{
+		"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":  "b7ad6b7169203331",
+		"x-b3-sampled": "1",
+	}.ContextWithSpan(ctx)
+	cancel()
+
+	done := make(chan struct{})
+	GoDetached(ctx, "send-mail", func(ctx context.Context) {
+		defer close(done)
+		if ctx.Err() != nil {
+			t.Errorf("detached ctx err = %v, want nil", ctx.Err())
+		}
+		if traceID := athCtx.GetTraceId(ctx); traceID != "trace-1" {
+			t.Errorf("traceId = %q, want trace-1", traceID)
+		}
+	})
+	<-done
+	// f返回后才结束span
+	for i := 0; i < 100 && reporter.SpansSubmitted() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	spans := reporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("spans submitted = %d, want 1", len(spans))
+	}
+	span := spans[0].(*jaeger.Span)
+	refs := span.References()
+	if span.OperationName() != "send-mail" || len(refs) != 1 || refs[0].Type != opentracing.FollowsFromRef {
+		t.Errorf("span = %s %v, want send-mail following from the request span", span.OperationName(), refs)
+	}
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+)
+
+const (
+	HeaderGray = "x-gray"
+
+	// QueryAccountHash BuildShareUrl 追加到外链中的账号hash参数
+	QueryAccountHash = "_acc_"
+)
+
+// GrayRule 灰度规则
+type GrayRule struct {
+	// Accounts 灰度账号
+	Accounts []string
+	// Percentage 按账号hash分桶的灰度比例，0-100
+	Percentage int
+}
+
+// GrayDecider 根据灰度规则判断账号是否在灰度中
+type GrayDecider struct {
+	accounts   map[string]struct{}
+	hashes     map[string]struct{}
+	percentage int
+}
+
+// NewGrayDecider 创建灰度判断
+func NewGrayDecider(rule GrayRule) *GrayDecider {
+	d := &GrayDecider{
+		accounts:   make(map[string]struct{}, len(rule.Accounts)),
+		hashes:     make(map[string]struct{}, len(rule.Accounts)),
+		percentage: rule.Percentage,
+	}
+	for _, account := range rule.Accounts {
+		d.accounts[account] = struct{}{}
+		d.hashes[MD5(account)] = struct{}{}
+	}
+	return d
+}
+
+// Decide 判断请求是否在灰度中，accountID 为空时使用外链中的 _acc_ 参数
+func (d *GrayDecider) Decide(accountID, accHash string) bool {
+	if accountID != "" && accountID != "-" {
+		if _, ok := d.accounts[accountID]; ok {
+			return true
+		}
+		return d.inBucket(MD5(accountID))
+	}
+	if accHash != "" {
+		if _, ok := d.hashes[accHash]; ok {
+			return true
+		}
+		return d.inBucket(accHash)
+	}
+	return false
+}
+
+// inBucket 账号和 _acc_ 都使用账号md5分桶，保证同一账号的结果一致
+func (d *GrayDecider) inBucket(accHash string) bool {
+	if d.percentage <= 0 || len(accHash) < 8 {
+		return false
+	}
+	n, err := strconv.ParseUint(accHash[:8], 16, 32)
+	if err != nil {
+		return false
+	}
+	return int(n%100) < d.percentage
+}
+
+func (span Span) IsGray() bool {
+	v := span.Get(HeaderGray)
+	return v == "1" || v == "true"
+}
+
+func (span Span) SetGray(gray bool) {
+	if gray {
+		span.Set(HeaderGray, "1")
+	} else {
+		span.Set(HeaderGray, "0")
+	}
+}
+
+// NewGrayContext 标记context是否在灰度中，标记会随 Inject 传递给下游
+func NewGrayContext(ctx context.Context, gray bool) context.Context {
+	span := SpanFromContext(ctx)
+	span.SetGray(gray)
+	return ContextWithSpan(ctx, span)
+}
+
+// IsGray 判断context是否在灰度中
+func IsGray(ctx context.Context) bool {
+	return SpanFromContext(ctx).IsGray()
+}
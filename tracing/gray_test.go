@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestGrayDecider_Decide(t *testing.T) {
+	d := NewGrayDecider(GrayRule{Accounts: []string{"W00000000514"}})
+
+	tests := []struct {
+		name      string
+		accountID string
+		accHash   string
+		want      bool
+	}{
+		{"gray account", "W00000000514", "", true},
+		{"normal account", "W00000000512", "", false},
+		{"irrelevant account", "-", "", false},
+		{"gray share url", "", "465b7f1355597d9a32889191ee64a4fb", true},
+		{"normal share url", "", "57999f24c5055fc8791730eedf36ad78", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Decide(tt.accountID, tt.accHash); got != tt.want {
+				t.Errorf("Decide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrayDecider_Percentage(t *testing.T) {
+	d := NewGrayDecider(GrayRule{Percentage: 30})
+	for _, account := range []string{"W00000000514", "W00000000512", "W00000000001"} {
+		if d.Decide(account, "") != d.Decide("", MD5(account)) {
+			t.Errorf("account %s and its share url hash should be in the same bucket", account)
+		}
+	}
+}
+
+func TestNewGrayContext(t *testing.T) {
+	ctx := NewGrayContext(NewContext(context.TODO(), "W00000000514"), true)
+	if !IsGray(ctx) {
+		t.Fatal("context should be gray")
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err := SpanFromContext(ctx).Inject(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get(HeaderGray) != "1" {
+		t.Errorf("gray header = %q, want 1", r.Header.Get(HeaderGray))
+	}
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/hlhgogo/gin-ext/app"
+	athCtx "github.com/hlhgogo/gin-ext/context"
+	athLog "github.com/hlhgogo/gin-ext/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor 提取上游链路信息、创建server span，并把panic转换为 codes.Internal
+func UnaryServerInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, serverSpan := startGrpcServerSpan(ctx, info.FullMethod)
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = grpcPanicError(ctx, info.FullMethod, r)
+		}
+		finishGrpcServerSpan(ctx, serverSpan, info.FullMethod, start, err)
+	}()
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor 提取上游链路信息、创建server span，并把panic转换为 codes.Internal
+func StreamServerInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) (err error) {
+	ctx, serverSpan := startGrpcServerSpan(ss.Context(), info.FullMethod)
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = grpcPanicError(ctx, info.FullMethod, r)
+		}
+		finishGrpcServerSpan(ctx, serverSpan, info.FullMethod, start, err)
+	}()
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// serverStream 替换 ServerStream 的context
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// startGrpcServerSpan 与 middlewares.Trace 一致，把请求id写入 CtxValue 的 traceId
+func startGrpcServerSpan(ctx context.Context, method string) (context.Context, opentracing.Span) {
+	span, _ := ExtractFromGrpc(ctx)
+	requestID := span.RequestID()
+	if requestID == "" {
+		requestID = app.NewUUIDv4()
+	}
+	span.Set(HeaderRequestID, requestID)
+
+	serverSpan, span := span.StartServerSpan(method)
+	ext.Component.Set(serverSpan, "grpc")
+	serverSpan.SetTag("rpc.method", method)
+	ctx = opentracing.ContextWithSpan(ctx, serverSpan)
+	ctx = span.ContextWithSpan(ctx)
+
+	athValue := athCtx.GetCtxValue(ctx)
+	commonValue := athValue.GetCommonValue()
+	commonValue[athCtx.CtxValueCommonKeyTraceID] = requestID
+	ctx, _ = athCtx.SetCtxValue(ctx, athValue.SetCommonValue(commonValue))
+	return ctx, serverSpan
+}
+
+// finishGrpcServerSpan 记录状态码和耗时后结束server span
+func finishGrpcServerSpan(ctx context.Context, serverSpan opentracing.Span, method string, start time.Time, err error) {
+	code := status.Code(err)
+	serverSpan.SetTag("rpc.grpc.status_code", uint32(code))
+	fields := logrus.Fields{
+		"method":     method,
+		"code":       code.String(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		ext.Error.Set(serverSpan, true)
+		serverSpan.LogKV("event", "error", "message", err.Error())
+		fields["error"] = err.Error()
+		athLog.WarnFieldsWithTrace(ctx, fields, "gRPC request failed")
+	} else {
+		athLog.InfoFieldsWithTrace(ctx, fields, "gRPC request")
+	}
+	serverSpan.Finish()
+}
+
+// grpcPanicError 记录panic并返回 codes.Internal，不把panic内容返回给调用方
+func grpcPanicError(ctx context.Context, method string, r interface{}) error {
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", r)
+	}
+	athLog.ErrorFieldsWithTrace(ctx, logrus.Fields{"method": method, "panic_stack": string(debug.Stack())}, err, "gRPC Panic")
+	return status.Error(codes.Internal, "internal server error")
+}
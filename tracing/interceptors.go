@@ -36,7 +36,7 @@ func Inject(r *http.Request, span Span) error {
 	if span == nil {
 		return nil
 	}
-	for k, v := range span {
+	for k, v := range span.propagationHeaders() {
 		r.Header.Set(k, v)
 	}
 	return nil
@@ -44,7 +44,7 @@ func Inject(r *http.Request, span Span) error {
 
 func InjectToGrpc(ctx context.Context, span Span) context.Context {
 	kv := []string{}
-	for k, v := range span {
+	for k, v := range span.propagationHeaders() {
 		kv = append(kv, k, v)
 	}
 	return metadata.AppendToOutgoingContext(ctx, kv...)
@@ -123,6 +123,7 @@ func extract(headers map[string][]string, extHeaders ...string) Span {
 			span[key] = value
 		}
 	}
+	span.normalize()
 	return span
 }
 
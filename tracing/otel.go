@@ -0,0 +1,231 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelBridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	BackendJaeger = "jaeger"
+	BackendOTLP   = "otlp"
+	BackendStdout = "stdout"
+
+	backendEnv = "TRACING_BACKEND"
+
+	headerTraceparent = "traceparent"
+)
+
+// OpenTelemetryOptions OpenTelemetry 后端配置，通过 opentracing bridge 保持 Span、Extract、Inject 的用法不变
+type OpenTelemetryOptions struct {
+	// OTLPEndpoint OTLP/HTTP 地址，如 otel-collector:4318，为空时读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	OTLPEndpoint string
+	// OTLPInsecure 使用http上报
+	OTLPInsecure bool
+	// ExportFile stdout 后端写入的文件，为空时输出到标准输出
+	ExportFile string
+	// ExportWriter stdout 后端的输出，优先于 ExportFile
+	ExportWriter io.Writer
+	// SpanExporter 自定义exporter，优先于 backend 对应的exporter，测试时可以使用 tracetest.NewInMemoryExporter()
+	SpanExporter sdktrace.SpanExporter
+}
+
+// defaultTracerProvider otel 默认的全局 TracerProvider，第一次 SetTracerProvider 后会一直委托给设置的provider，
+// 不能在 Shutdown 时恢复
+var defaultTracerProvider = otel.GetTracerProvider()
+
+// closerFunc 把关闭函数转换为 io.Closer
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// initOpenTelemetry 使用 OpenTelemetry SDK 作为全局tracer
+func initOpenTelemetry(backend string, cfg *jaegercfg.Configuration, opts Options) error {
+	var file *os.File
+	var processor sdktrace.SpanProcessor
+	if opts.SpanExporter != nil {
+		processor = sdktrace.NewSimpleSpanProcessor(opts.SpanExporter)
+	} else {
+		var exporter sdktrace.SpanExporter
+		var err error
+		switch backend {
+		case BackendOTLP:
+			var clientOpts []otlptracehttp.Option
+			if opts.OTLPEndpoint != "" {
+				clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.OTLPEndpoint))
+			}
+			if opts.OTLPInsecure {
+				clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+			}
+			exporter, err = otlptracehttp.New(context.Background(), clientOpts...)
+		case BackendStdout:
+			writer := opts.ExportWriter
+			if writer == nil && opts.ExportFile != "" {
+				if file, err = os.OpenFile(opts.ExportFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+					return err
+				}
+				writer = file
+			}
+			if writer == nil {
+				writer = os.Stdout
+			}
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+		}
+		if err != nil {
+			if file != nil {
+				file.Close()
+			}
+			return err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(otelSampler(cfg.Sampler))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	tracer, wrapperProvider := otelBridge.NewTracerPair(tp.Tracer(cfg.ServiceName))
+	tracer.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	// Shutdown 时恢复之前的全局 TracerProvider，之前是默认provider时恢复为 NoopTracerProvider
+	previous := otel.GetTracerProvider()
+	if previous == defaultTracerProvider {
+		previous = trace.NewNoopTracerProvider()
+	}
+	otel.SetTracerProvider(wrapperProvider)
+	opentracing.SetGlobalTracer(tracer)
+	log.Println("OpenTelemetry backend:", backend)
+
+	closer = closerFunc(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := tp.Shutdown(ctx)
+		otel.SetTracerProvider(previous)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	})
+	return nil
+}
+
+// otelSampler 把jaeger的采样配置转换为 OpenTelemetry 的采样器
+func otelSampler(conf *jaegercfg.SamplerConfig) sdktrace.Sampler {
+	switch conf.Type {
+	case jaeger.SamplerTypeConst:
+		if conf.Param > 0 {
+			return sdktrace.AlwaysSample()
+		}
+		return sdktrace.NeverSample()
+	case jaeger.SamplerTypeProbabilistic:
+		return sdktrace.TraceIDRatioBased(conf.Param)
+	default:
+		return sdktrace.AlwaysSample()
+	}
+}
+
+// bridgeTracer 全局tracer是 OpenTelemetry bridge 时返回
+func bridgeTracer() *otelBridge.BridgeTracer {
+	t, _ := opentracing.GlobalTracer().(*otelBridge.BridgeTracer)
+	return t
+}
+
+// otelSpanContext 把b3字段转换为 traceparent 后由bridge解析
+func (span Span) otelSpanContext(tracer *otelBridge.BridgeTracer) (opentracing.SpanContext, error) {
+	traceID, spanID := span.traceID(), span.SpanID()
+	if traceID == "" || spanID == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	sampled := span.Get("x-b3-sampled")
+	header := http.Header{}
+	header.Set(headerTraceparent, formatTraceparent(traceID, spanID, sampled == "1" || sampled == "true"))
+	return tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+}
+
+// otelSpanIDs 通过bridge注入 traceparent 获取 OpenTelemetry span 的 trace id 和 span id
+func otelSpanIDs(tracer *otelBridge.BridgeTracer, spanCtx opentracing.SpanContext) (traceID, spanID string, sampled, ok bool) {
+	header := http.Header{}
+	if err := tracer.Inject(spanCtx, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		return "", "", false, false
+	}
+	return parseTraceparent(header.Get(headerTraceparent))
+}
+
+// spanFromOtel 读取 OpenTelemetry span 的id和baggage，OpenTelemetry 的span context 不包含parent id
+func spanFromOtel(tracer *otelBridge.BridgeTracer, span opentracing.Span) Span {
+	traceID, spanID, sampled, ok := otelSpanIDs(tracer, span.Context())
+	if !ok {
+		return Span{}
+	}
+
+	newSpan := Span{}
+	newSpan.Set("x-b3-traceid", traceID)
+	newSpan.Set("x-b3-spanid", spanID)
+	if sampled {
+		newSpan.Set("x-b3-sampled", "1")
+	}
+
+	span.Context().ForeachBaggageItem(func(k, v string) bool {
+		newSpan.Set(k, v)
+		return true
+	})
+
+	return newSpan
+}
+
+// formatTraceparent 生成 W3C traceparent，64位的b3 trace id 左侧补0
+func formatTraceparent(traceID, spanID string, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%032s-%016s-%s", strings.ToLower(traceID), strings.ToLower(spanID), flags)
+}
+
+// parseTraceparent 解析 W3C traceparent
+func parseTraceparent(value string) (traceID, spanID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false, false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || !isHex(parts[3]) ||
+		parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return "", "", false, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return "", "", false, false
+	}
+	return parts[1], parts[2], flags&1 == 1, true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
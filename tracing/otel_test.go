@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInitOpenTelemetry(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Init(Options{Backend: BackendOTLP, ServiceName: "tracing-test", OpenTelemetryOptions: OpenTelemetryOptions{SpanExporter: exporter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	parent := NewSpan("parent")
+	if parent.traceID() == "" || parent.SpanID() == "" {
+		t.Fatalf("span ids should be set, got %v", parent)
+	}
+	child := parent.StartChildSpan("child")
+	child.Finish()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	if got := spans[0].SpanContext.TraceID().String(); got != parent.traceID() {
+		t.Errorf("child trace id = %s, want %s", got, parent.traceID())
+	}
+	if got := spans[0].Parent.SpanID().String(); got != parent.SpanID() {
+		t.Errorf("child parent id = %s, want %s", got, parent.SpanID())
+	}
+
+	childSpan := SpanFromOpentracing(child)
+	if childSpan.traceID() != parent.traceID() || childSpan.SpanID() == parent.SpanID() {
+		t.Errorf("unexpected child span %v", childSpan)
+	}
+}
+
+func TestShutdownOpenTelemetry(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := Init(Options{Backend: BackendOTLP, ServiceName: "tracing-test", OpenTelemetryOptions: OpenTelemetryOptions{SpanExporter: exporter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认provider已经委托给关闭的provider，Shutdown 后恢复为 NoopTracerProvider
+	_, span := otel.Tracer("after-shutdown").Start(context.Background(), "noop")
+	span.End()
+	if span.SpanContext().IsValid() || len(exporter.GetSpans()) != 0 {
+		t.Error("otel global tracer should be a noop tracer after shutdown")
+	}
+
+	previous := trace.NewNoopTracerProvider()
+	otel.SetTracerProvider(previous)
+	shutdown, err = Init(Options{Backend: BackendOTLP, ServiceName: "tracing-test", OpenTelemetryOptions: OpenTelemetryOptions{SpanExporter: exporter}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() == previous {
+		t.Error("Init should replace the otel global tracer provider")
+	}
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != previous {
+		t.Error("shutdown should restore the previous otel global tracer provider")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		sampled bool
+		ok      bool
+	}{
+		{"sampled", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true, true},
+		{"not sampled", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00", false, true},
+		{"zero trace id", "00-00000000000000000000000000000000-b7ad6b7169203331-01", false, false},
+		{"invalid version", "ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", false, false},
+		{"upper case", "00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01", false, false},
+		{"short", "00-0af7651916cd43dd-b7ad6b7169203331-01", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, sampled, ok := parseTraceparent(tt.value)
+			if ok != tt.ok || sampled != tt.sampled {
+				t.Errorf("parseTraceparent() = %v, %v, want %v, %v", sampled, ok, tt.sampled, tt.ok)
+			}
+		})
+	}
+	if got := formatTraceparent("b7ad6b7169203331", "b7ad6b7169203331", true); got != "00-0000000000000000b7ad6b7169203331-b7ad6b7169203331-01" {
+		t.Errorf("formatTraceparent() = %s", got)
+	}
+}
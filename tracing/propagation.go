@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	HeaderTraceparent = headerTraceparent
+	HeaderTracestate  = "tracestate"
+	HeaderBaggage     = "baggage"
+	HeaderB3          = "b3"
+)
+
+// traceContextHeaders W3C、b3 单header等需要与b3多header互相转换的header，不放入jaeger baggage
+var traceContextHeaders = map[string]struct{}{
+	HeaderTraceparent: {},
+	HeaderTracestate:  {},
+	HeaderBaggage:     {},
+	HeaderB3:          {},
+}
+
+// normalize 以b3多header为准补全trace id、span id，上游只传 traceparent 或 b3 单header时转换为b3多header
+func (span Span) normalize() {
+	if span.traceID() == "" || span.SpanID() == "" {
+		if traceID, spanID, sampled, ok := parseTraceparent(span.Get(HeaderTraceparent)); ok {
+			span.Set("x-b3-traceid", traceID)
+			span.Set("x-b3-spanid", spanID)
+			span.setSampled(sampled)
+		} else if b3 := span.Get(HeaderB3); b3 != "" {
+			span.setFromSingleB3(b3)
+		}
+	}
+	if span.traceID() != "" && span.SpanID() != "" {
+		span.Set(HeaderTraceparent, formatTraceparent(span.traceID(), span.SpanID(), span.Sampled()))
+	}
+}
+
+// propagationHeaders 返回传递给下游的header，traceparent、b3 单header 与b3多header保持一致
+func (span Span) propagationHeaders() Span {
+	out := make(Span, len(span)+1)
+	for k, v := range span {
+		out[k] = v
+	}
+	if span.traceID() == "" || span.SpanID() == "" {
+		return out
+	}
+	out[HeaderTraceparent] = formatTraceparent(span.traceID(), span.SpanID(), span.Sampled())
+	if _, ok := span[HeaderB3]; ok {
+		out[HeaderB3] = span.singleB3()
+	}
+	return out
+}
+
+// Sampled b3 或 W3C 中的采样标记
+func (span Span) Sampled() bool {
+	sampled := span.Get("x-b3-sampled")
+	return sampled == "1" || sampled == "true" || span.Get("x-b3-flags") == "1"
+}
+
+func (span Span) setSampled(sampled bool) {
+	if sampled {
+		span.Set("x-b3-sampled", "1")
+	} else {
+		span.Set("x-b3-sampled", "0")
+	}
+}
+
+// setFromSingleB3 解析 b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+func (span Span) setFromSingleB3(value string) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) == 1 {
+		span.setB3SamplingState(parts[0])
+		return
+	}
+	if !isHex(strings.ToLower(parts[0])) || !isHex(strings.ToLower(parts[1])) ||
+		(len(parts[0]) != 16 && len(parts[0]) != 32) || len(parts[1]) != 16 {
+		return
+	}
+	span.Set("x-b3-traceid", strings.ToLower(parts[0]))
+	span.Set("x-b3-spanid", strings.ToLower(parts[1]))
+	if len(parts) > 2 {
+		span.setB3SamplingState(parts[2])
+	}
+	if len(parts) > 3 && len(parts[3]) == 16 && isHex(strings.ToLower(parts[3])) {
+		span.Set("x-b3-parentspanid", strings.ToLower(parts[3]))
+	}
+}
+
+func (span Span) setB3SamplingState(state string) {
+	switch state {
+	case "1":
+		span.setSampled(true)
+	case "0":
+		span.setSampled(false)
+	case "d":
+		span.Set("x-b3-flags", "1")
+	}
+}
+
+// singleB3 生成 b3 单header
+func (span Span) singleB3() string {
+	state := "0"
+	if span.Get("x-b3-flags") == "1" {
+		state = "d"
+	} else if span.Sampled() {
+		state = "1"
+	}
+	b3 := span.traceID() + "-" + span.SpanID() + "-" + state
+	if parentID := span.Get("x-b3-parentspanid"); parentID != "" {
+		b3 += "-" + parentID
+	}
+	return b3
+}
+
+// Tracestate W3C tracestate，原样传递给下游
+func (span Span) Tracestate() string {
+	return span.Get(HeaderTracestate)
+}
+
+// Baggage 解析 W3C baggage，忽略格式错误的条目和属性
+func (span Span) Baggage() map[string]string {
+	items := make(map[string]string)
+	for _, member := range strings.Split(span.Get(HeaderBaggage), ",") {
+		kv := strings.SplitN(strings.SplitN(member, ";", 2)[0], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if key == "" || err != nil {
+			continue
+		}
+		items[key] = value
+	}
+	return items
+}
+
+// BaggageItem 获取 W3C baggage 中的值
+func (span Span) BaggageItem(key string) string {
+	return span.Baggage()[key]
+}
+
+// SetBaggageItem 设置 W3C baggage 中的值，会随 Inject 传递给下游
+func (span Span) SetBaggageItem(key, value string) {
+	items := span.Baggage()
+	items[key] = value
+
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	members := make([]string, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, k+"="+url.PathEscape(items[k]))
+	}
+	span.Set(HeaderBaggage, strings.Join(members, ","))
+}
@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestExtract_Conversion(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		traceID     string
+		spanID      string
+		sampled     bool
+		traceparent string
+	}{
+		{
+			name:        "b3 multi to traceparent",
+			headers:     map[string]string{"X-B3-TraceId": "0af7651916cd43dd8448eb211c80319c", "X-B3-SpanId": "b7ad6b7169203331", "X-B3-Sampled": "1"},
+			traceID:     "0af7651916cd43dd8448eb211c80319c",
+			spanID:      "b7ad6b7169203331",
+			sampled:     true,
+			traceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		},
+		{
+			name:        "traceparent to b3",
+			headers:     map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"},
+			traceID:     "0af7651916cd43dd8448eb211c80319c",
+			spanID:      "b7ad6b7169203331",
+			sampled:     false,
+			traceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00",
+		},
+		{
+			name:        "b3 single to traceparent",
+			headers:     map[string]string{"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"},
+			traceID:     "80f198ee56343ba864fe8b2a57d3eff7",
+			spanID:      "e457b5a2e4d86bd1",
+			sampled:     true,
+			traceparent: "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01",
+		},
+		{
+			name:        "64 bit b3 trace id",
+			headers:     map[string]string{"X-B3-TraceId": "8448eb211c80319c", "X-B3-SpanId": "b7ad6b7169203331", "X-B3-Sampled": "1"},
+			traceID:     "8448eb211c80319c",
+			spanID:      "b7ad6b7169203331",
+			sampled:     true,
+			traceparent: "00-00000000000000008448eb211c80319c-b7ad6b7169203331-01",
+		},
+		{
+			name:    "invalid traceparent",
+			headers: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			span, err := Extract(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if span.traceID() != tt.traceID || span.SpanID() != tt.spanID || span.Sampled() != tt.sampled {
+				t.Errorf("span = %v, want trace id %s span id %s sampled %v", span, tt.traceID, tt.spanID, tt.sampled)
+			}
+			if tt.traceparent == "" {
+				return
+			}
+
+			out, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err := span.Inject(out); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.Header.Get(HeaderTraceparent); got != tt.traceparent {
+				t.Errorf("traceparent = %s, want %s", got, tt.traceparent)
+			}
+			if got := out.Header.Get("x-b3-traceid"); got != tt.traceID {
+				t.Errorf("x-b3-traceid = %s, want %s", got, tt.traceID)
+			}
+		})
+	}
+}
+
+func TestInject_RefreshesTraceContext(t *testing.T) {
+	span := Span{
+		"x-b3-traceid":    "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":     "e457b5a2e4d86bd1",
+		"x-b3-sampled":    "1",
+		HeaderTraceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		HeaderB3:          "0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-1",
+		HeaderTracestate:  "congo=t61rcWkgMzE",
+	}
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err := span.Inject(r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Header.Get(HeaderTraceparent), "00-0af7651916cd43dd8448eb211c80319c-e457b5a2e4d86bd1-01"; got != want {
+		t.Errorf("traceparent = %s, want %s", got, want)
+	}
+	if got, want := r.Header.Get(HeaderB3), "0af7651916cd43dd8448eb211c80319c-e457b5a2e4d86bd1-1"; got != want {
+		t.Errorf("b3 = %s, want %s", got, want)
+	}
+	if got := r.Header.Get(HeaderTracestate); got != "congo=t61rcWkgMzE" {
+		t.Errorf("tracestate = %s, want it unchanged", got)
+	}
+
+	ctx := span.InjectToGrpc(context.TODO())
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if got := md.Get(HeaderTraceparent); len(got) != 1 || got[0] != "00-0af7651916cd43dd8448eb211c80319c-e457b5a2e4d86bd1-01" {
+		t.Errorf("grpc traceparent = %v", got)
+	}
+}
+
+func TestSpan_Baggage(t *testing.T) {
+	span := Span{HeaderBaggage: "userId=alice, serverNode=DF%2028;prop=1,broken"}
+	if got := span.BaggageItem("serverNode"); got != "DF 28" {
+		t.Errorf("serverNode = %q, want %q", got, "DF 28")
+	}
+	if got := span.BaggageItem("userId"); got != "alice" {
+		t.Errorf("userId = %q, want alice", got)
+	}
+
+	span.SetBaggageItem("tenant", "acme corp")
+	if got, want := span.Get(HeaderBaggage), "serverNode=DF%2028,tenant=acme%20corp,userId=alice"; got != want {
+		t.Errorf("baggage = %s, want %s", got, want)
+	}
+}
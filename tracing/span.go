@@ -5,14 +5,18 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/uber/jaeger-client-go"
 )
 
 const (
 	HeaderRequestID     = "x-request-id"
 	HeaderAuthAccountID = "x-auth-accountid"
+	HeaderAuthTenantID  = "x-auth-tenantid"
 )
 
 type contextKey struct{}
@@ -35,6 +39,13 @@ var (
 		// real ip
 		"x-forwarded-for",
 		"x-real-ip",
+		// gray release
+		HeaderGray,
+		// W3C trace context and b3 single header
+		HeaderTraceparent,
+		HeaderTracestate,
+		HeaderBaggage,
+		HeaderB3,
 	}
 
 	applicationHeaderPrefix = []string{
@@ -57,6 +68,11 @@ func (span Span) Get(key string) string {
 	return span[key]
 }
 
+// Del 删除key，删除后不再随 Inject 传递给下游
+func (span Span) Del(key string) {
+	delete(span, key)
+}
+
 func (span Span) RequestID() string {
 	id := span.istioRequestID()
 	if len(id) > 0 {
@@ -85,8 +101,16 @@ func (span Span) SetAuthAccountID(accountID string) {
 	span.Set(HeaderAuthAccountID, accountID)
 }
 
+func (span Span) TenantID() string {
+	return span.Get(HeaderAuthTenantID)
+}
+
+func (span Span) SetTenantID(tenantID string) {
+	span.Set(HeaderAuthTenantID, tenantID)
+}
+
 func (span Span) IsIstio() bool {
-	return istioOpen
+	return atomic.LoadInt32(&istioOpen) == 1
 }
 
 func (span Span) Empty() bool {
@@ -106,6 +130,10 @@ func (span Span) ContextWithSpan(context context.Context) context.Context {
 }
 
 func (span Span) SpanContext() (opentracing.SpanContext, error) {
+	if tracer := bridgeTracer(); tracer != nil {
+		return span.otelSpanContext(tracer)
+	}
+
 	var (
 		traceID  jaeger.TraceID
 		spanID   uint64
@@ -116,6 +144,9 @@ func (span Span) SpanContext() (opentracing.SpanContext, error) {
 	)
 
 	for key, value := range span {
+		if _, ok := traceContextHeaders[key]; ok {
+			continue
+		}
 		if key == "x-b3-traceid" {
 			traceID, err = jaeger.TraceIDFromString(value)
 		} else if key == "x-b3-parentspanid" {
@@ -154,7 +185,7 @@ func NewAccountIrrelevantContext(ctx context.Context) context.Context {
 	return NewContext(ctx, "-")
 }
 
-// CopyContext 将原始 context 链路信息复制到新 context 中
+// CopyContext 将原始 context 链路信息复制到新 context 中，只复制span，需要保留 CtxValue 等内容时使用 context.Detach
 func CopyContext(new, old context.Context) context.Context {
 	if new == nil {
 		new = context.TODO()
@@ -164,9 +195,18 @@ func CopyContext(new, old context.Context) context.Context {
 }
 
 func (span Span) StartChildSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return span.startSpan(operationName, opentracing.ChildOf, opts...)
+}
+
+// StartFollowsFromSpan 创建与span是 follows-from 关系的span，用于不阻塞调用方的异步任务
+func (span Span) StartFollowsFromSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return span.startSpan(operationName, opentracing.FollowsFrom, opts...)
+}
+
+func (span Span) startSpan(operationName string, reference func(opentracing.SpanContext) opentracing.SpanReference, opts ...opentracing.StartSpanOption) opentracing.Span {
 	parent, err := span.SpanContext()
 	if err == nil {
-		opts = append(opts, opentracing.ChildOf(parent))
+		opts = append(opts, reference(parent))
 	}
 	newspan := opentracing.StartSpan(operationName, opts...)
 	accountID := span.AuthAccountID()
@@ -181,6 +221,35 @@ func (span Span) StartChildSpan(operationName string, opts ...opentracing.StartS
 	return newspan
 }
 
+// StartServerSpan 以span为父span创建server span，返回的Span使用server span的id，
+// 传递给下游后会成为它的子span
+func (span Span) StartServerSpan(operationName string, opts ...opentracing.StartSpanOption) (opentracing.Span, Span) {
+	opts = append(opts, ext.SpanKindRPCServer)
+	serverSpan := span.StartChildSpan(operationName, opts...)
+	return serverSpan, span.WithOpentracing(serverSpan)
+}
+
+// WithOpentracing 返回使用 opentracing span id 的副本，没有初始化tracer时保留原来的id
+func (span Span) WithOpentracing(otSpan opentracing.Span) Span {
+	newSpan := Span{}
+	for key, value := range span {
+		newSpan.Set(key, value)
+	}
+	ids := SpanFromOpentracing(otSpan)
+	if ids.Empty() {
+		return newSpan
+	}
+	for key := range newSpan {
+		if strings.HasPrefix(key, "x-b3-") {
+			delete(newSpan, key)
+		}
+	}
+	for key, value := range ids {
+		newSpan.Set(key, value)
+	}
+	return newSpan
+}
+
 func StartChildSpan(operationName string, span opentracing.Span, opts ...opentracing.StartSpanOption) opentracing.Span {
 	opts = append(opts, opentracing.ChildOf(span.Context()))
 	newspan := opentracing.StartSpan(operationName, opts...)
@@ -204,6 +273,12 @@ func NewSpan(operationName string) Span {
 }
 
 func tagOpentracingSpan(span opentracing.Span) {
+	if tracer := bridgeTracer(); tracer != nil {
+		if traceID, _, _, ok := otelSpanIDs(tracer, span.Context()); ok {
+			span.SetTag("x-b3-traceid", traceID)
+		}
+		return
+	}
 	spanCtx, ok := span.Context().(jaeger.SpanContext)
 	if !ok {
 		return
@@ -212,6 +287,9 @@ func tagOpentracingSpan(span opentracing.Span) {
 }
 
 func SpanFromOpentracing(span opentracing.Span) Span {
+	if tracer := bridgeTracer(); tracer != nil {
+		return spanFromOtel(tracer, span)
+	}
 	spanCtx, ok := span.Context().(jaeger.SpanContext)
 	if !ok {
 		return Span{}
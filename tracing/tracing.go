@@ -1,14 +1,18 @@
 package tracing
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hlhgogo/config"
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
@@ -21,70 +25,171 @@ const (
 )
 
 var (
-	AppName   string
-	istioOpen bool
+	AppName string
+	// istioOpen detectIstio 在goroutine中写入，1表示运行在istio sidecar中，需要使用atomic读写
+	istioOpen int32
 )
 
-func init() {
-	var stopChan = make(chan os.Signal, 2)
-	signal.Notify(stopChan, syscall.SIGTERM)
+var (
+	initMu sync.Mutex
+	closer io.Closer
+
+	ErrAlreadyInitialized = errors.New("tracing: tracer already initialized")
+)
+
+// Options 链路追踪初始化配置，为空的字段从 JAEGER_* 环境变量读取
+type Options struct {
+	// Backend 链路后端 jaeger、otlp、stdout，默认读取环境变量 TRACING_BACKEND，都为空时使用 jaeger
+	Backend string
+	// ServiceName 服务名，默认读取环境变量，都为空时使用 app.name
+	ServiceName string
+	// Disabled 不上报链路，GlobalTracer 保持为 NoopTracer
+	Disabled bool
+	// CollectorEndpoint jaeger collector地址，如 http://jaeger-collector:14268/api/traces
+	CollectorEndpoint string
+	// AgentHostPort jaeger agent地址，如 127.0.0.1:6831
+	AgentHostPort string
+	// SamplerType 采样类型，默认 const
+	SamplerType string
+	// SamplerParam 采样参数，SamplerType 为空时默认 1
+	SamplerParam float64
+	// Reporter jaeger自定义reporter，优先于 CollectorEndpoint、AgentHostPort，测试时可以使用 jaeger.NewInMemoryReporter()
+	Reporter jaeger.Reporter
+	// DetectIstio 探测是否运行在istio sidecar中
+	DetectIstio bool
 
-	//go tracer(stopChan)
-	//go detectIstio()
+	OpenTelemetryOptions
+}
+
+func init() {
 	parsePropagateHeaders()
 }
 
-func parsePropagateHeaders() {
-	headers := os.Getenv(passthroughHeader)
-	for _, h := range strings.Split(headers, ",") {
-		if len(h) > 0 {
-			headersToPropagate = append(headersToPropagate, strings.TrimSpace(h))
-		}
-	}
-	headers = os.Getenv(passthroughHeaderPrefix)
-	for _, h := range strings.Split(headers, ",") {
-		if len(h) > 0 {
-			applicationHeaderPrefix = append(applicationHeaderPrefix, strings.TrimSpace(h))
-		}
+// Init 初始化全局tracer，返回的shutdown会上报缓存中的span并恢复为 NoopTracer，OpenTelemetry 后端同时恢复 otel 全局 TracerProvider，
+// 需要在服务退出时调用
+func Init(opts Options) (shutdown func() error, err error) {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if closer != nil {
+		return nil, ErrAlreadyInitialized
 	}
-}
 
-func tracer(stop chan os.Signal) {
+	if opts.DetectIstio {
+		go detectIstio()
+	}
 
 	cfg, err := jaegercfg.FromEnv()
 	if err != nil {
-		log.Println("Jaeger read configuration from Env failed:", err)
-		return
-	}
-	if cfg.Disabled {
-		log.Println("Jaeger disabled")
-		return
+		return nil, err
 	}
-	if len(cfg.Reporter.CollectorEndpoint) > 0 {
-		log.Println("Jaeger collectorEndpoint:", cfg.Reporter.CollectorEndpoint)
-	} else {
-		log.Println("Jaeger localAgentHostPort:", cfg.Reporter.LocalAgentHostPort)
+	if opts.Disabled || cfg.Disabled {
+		log.Println("Tracing disabled")
+		return func() error { return nil }, nil
 	}
 
-	if len(cfg.ServiceName) == 0 {
-		cfg.ServiceName = ServiceName()
-	}
+	cfg.ServiceName = resolveServiceName(opts.ServiceName, cfg.ServiceName)
 	AppName = cfg.ServiceName
 
+	if opts.SamplerType != "" {
+		cfg.Sampler.Type = opts.SamplerType
+		cfg.Sampler.Param = opts.SamplerParam
+	}
 	if len(cfg.Sampler.Type) == 0 {
 		cfg.Sampler.Type = "const"
 		cfg.Sampler.Param = 1
 	}
+
+	backend := opts.Backend
+	if backend == "" {
+		backend = os.Getenv(backendEnv)
+	}
+	switch backend {
+	case "", BackendJaeger:
+		err = initJaeger(cfg, opts)
+	case BackendOTLP, BackendStdout:
+		err = initOpenTelemetry(backend, cfg, opts)
+	default:
+		err = fmt.Errorf("tracing: unknown backend %q", backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return shutdownTracer, nil
+}
+
+// initJaeger 使用 jaeger-client-go 作为全局tracer
+func initJaeger(cfg *jaegercfg.Configuration, opts Options) error {
+	if opts.CollectorEndpoint != "" {
+		cfg.Reporter.CollectorEndpoint = opts.CollectorEndpoint
+	}
+	if opts.AgentHostPort != "" {
+		cfg.Reporter.LocalAgentHostPort = opts.AgentHostPort
+	}
 	cfg.Gen128Bit = true
-	logger := jaegercfg.Logger(jaeger.StdLogger)
-	tracer, closer, err := cfg.NewTracer(logger)
+
+	var tracer opentracing.Tracer
+	var c io.Closer
+	var err error
+	if opts.Reporter != nil {
+		tracer, c, err = cfg.NewTracer(jaegercfg.Reporter(opts.Reporter))
+	} else {
+		if len(cfg.Reporter.CollectorEndpoint) > 0 {
+			log.Println("Jaeger collectorEndpoint:", cfg.Reporter.CollectorEndpoint)
+		} else {
+			log.Println("Jaeger localAgentHostPort:", cfg.Reporter.LocalAgentHostPort)
+		}
+		tracer, c, err = cfg.NewTracer(jaegercfg.Logger(jaeger.StdLogger))
+	}
 	if err != nil {
-		log.Println("Jaeger new tracer failed:", err)
-		return
+		return err
 	}
-	defer closer.Close()
+
 	opentracing.SetGlobalTracer(tracer)
-	<-stop
+	closer = c
+	return nil
+}
+
+// shutdownTracer 关闭tracer并上报缓存中的span
+func shutdownTracer() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if closer == nil {
+		return nil
+	}
+	err := closer.Close()
+	closer = nil
+	opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	return err
+}
+
+// resolveServiceName 依次使用配置、环境变量、app.name、主机名推断的服务名
+func resolveServiceName(names ...string) string {
+	for _, name := range names {
+		if len(name) > 0 {
+			return name
+		}
+	}
+	if os.Getenv("SERVICE_NAME") == "" {
+		if conf := config.Get(); conf != nil && conf.App.Name != "" {
+			return conf.App.Name
+		}
+	}
+	return ServiceName()
+}
+
+func parsePropagateHeaders() {
+	headers := os.Getenv(passthroughHeader)
+	for _, h := range strings.Split(headers, ",") {
+		if len(h) > 0 {
+			headersToPropagate = append(headersToPropagate, strings.TrimSpace(h))
+		}
+	}
+	headers = os.Getenv(passthroughHeaderPrefix)
+	for _, h := range strings.Split(headers, ",") {
+		if len(h) > 0 {
+			applicationHeaderPrefix = append(applicationHeaderPrefix, strings.TrimSpace(h))
+		}
+	}
 }
 
 func ServiceName() string {
@@ -134,7 +239,7 @@ func detectIstio() {
 	if conn != nil {
 		conn.Close()
 		log.Println("Istio sidecar running")
-		istioOpen = true
+		atomic.StoreInt32(&istioOpen, 1)
 		return
 	}
 }
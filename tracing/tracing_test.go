@@ -1,8 +1,14 @@
 package tracing
 
 import (
+	"net"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 )
 
 func TestServiceName(t *testing.T) {
@@ -39,3 +45,47 @@ func TestServiceName(t *testing.T) {
 		t.Log(appName)
 	}
 }
+
+func TestInit(t *testing.T) {
+	reporter := jaeger.NewInMemoryReporter()
+	shutdown, err := Init(Options{ServiceName: "tracing-test", Reporter: reporter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Init(Options{}); err != ErrAlreadyInitialized {
+		t.Errorf("second Init() error = %v, want %v", err, ErrAlreadyInitialized)
+	}
+
+	span := StartNewSpan("test")
+	span.Finish()
+	if n := reporter.SpansSubmitted(); n != 1 {
+		t.Errorf("spans submitted = %d, want 1", n)
+	}
+	if err := shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if AppName != "tracing-test" {
+		t.Errorf("AppName = %q, want tracing-test", AppName)
+	}
+	if _, ok := opentracing.GlobalTracer().(opentracing.NoopTracer); !ok {
+		t.Error("global tracer should be reset after shutdown")
+	}
+}
+
+func TestDetectIstio(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:15000")
+	if err != nil {
+		t.Skip("port 15000 is not available:", err)
+	}
+	defer ln.Close()
+	defer atomic.StoreInt32(&istioOpen, 0)
+
+	go detectIstio()
+	deadline := time.Now().Add(2 * time.Second)
+	for !(Span{}).IsIstio() {
+		if time.Now().After(deadline) {
+			t.Fatal("IsIstio() = false, want true after sidecar is detected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}